@@ -2,6 +2,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/fast-ish/${{values.name}}/internal/cli"
@@ -62,6 +63,7 @@ func main() {
 
 	// Execute CLI
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: %s\n", cli.ErrorMessage(err))
+		os.Exit(cli.ExitCode(err))
 	}
 }
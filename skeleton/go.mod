@@ -24,18 +24,18 @@ require (
 {%- elif values.outputFormat == "tablewriter" %}
 	github.com/olekukonko/tablewriter v0.0.5
 {%- endif %}
-{%- if values.aiProvider == "bedrock" %}
+{%- if values.aiProvider != "none" %}
 	github.com/aws/aws-sdk-go-v2 v1.32.6
 	github.com/aws/aws-sdk-go-v2/config v1.28.6
-	github.com/aws/aws-sdk-go-v2/service/bedrockruntime v1.22.1
-{%- elif values.aiProvider == "openai" %}
+	github.com/aws/aws-sdk-go-v2/service/bedrock v1.25.0
+	github.com/aws/aws-sdk-go-v2/service/bedrockruntime v1.23.0
 	github.com/sashabaranov/go-openai v1.35.6
-{%- elif values.aiProvider == "anthropic" %}
 	github.com/anthropics/anthropic-sdk-go v0.2.0-alpha.5
-{%- elif values.aiProvider == "ollama" %}
 	github.com/ollama/ollama v0.4.7
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
+	golang.org/x/time v0.8.0
 {%- endif %}
-{%- if "aws" in values.integrations %}
+{%- if "aws" in values.integrations and values.aiProvider == "none" %}
 	github.com/aws/aws-sdk-go-v2 v1.32.6
 	github.com/aws/aws-sdk-go-v2/config v1.28.6
 {%- endif %}
@@ -55,12 +55,14 @@ require (
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.32.0
 	go.opentelemetry.io/otel/sdk v1.32.0
 {%- endif %}
-{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+{%- if values.configFormat == "yaml" or values.configFormat == "all" or values.aiProvider != "none" %}
 	gopkg.in/yaml.v3 v3.0.1
 {%- endif %}
 {%- if values.configFormat == "toml" or values.configFormat == "all" %}
 	github.com/pelletier/go-toml/v2 v2.2.3
 {%- endif %}
+	filippo.io/age v1.2.1
+	golang.org/x/term v0.22.0
 )
 
 require (
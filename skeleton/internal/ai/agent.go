@@ -0,0 +1,112 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultMaxSteps bounds the model turns in one agent run
+const DefaultMaxSteps = 10
+
+// ApproveFunc decides whether a tool call that changes state may run. It
+// returns nil to allow the call, or an error that is reported to the model
+// in place of the result.
+type ApproveFunc func(call ToolCall, tool Tool) error
+
+// Agent runs a conversation in which the model may call tools, feeding the
+// results back until it gives a final answer
+type Agent struct {
+	client  *Client
+	tools   *ToolRegistry
+	approve ApproveFunc
+
+	// MaxSteps bounds the model turns in one Run
+	MaxSteps int
+	// OnToolCall, if set, is called before each tool call runs
+	OnToolCall func(call ToolCall)
+}
+
+// NewAgent creates an agent that offers tools to the client's model.
+// Mutating tools only run when approve allows them; a nil approve denies
+// every mutating call.
+func NewAgent(client *Client, tools *ToolRegistry, approve ApproveFunc) *Agent {
+	return &Agent{
+		client:   client,
+		tools:    tools,
+		approve:  approve,
+		MaxSteps: DefaultMaxSteps,
+	}
+}
+
+// Run continues the conversation until the model replies without calling
+// a tool. It returns the conversation including every tool call and result;
+// the last message is the model's final answer.
+func (a *Agent) Run(ctx context.Context, messages []Message) ([]Message, error) {
+	for step := 0; step < a.MaxSteps; step++ {
+		req := a.client.request(messages, a.client.opts)
+		req.Tools = a.tools.List()
+
+		resp, err := a.client.chat(ctx, req)
+		if err != nil {
+			return messages, err
+		}
+
+		messages = append(messages, Message{
+			Role:      RoleAssistant,
+			Content:   resp.Text,
+			ToolCalls: resp.ToolCalls,
+		})
+		if len(resp.ToolCalls) == 0 {
+			return messages, nil
+		}
+
+		for _, call := range resp.ToolCalls {
+			messages = append(messages, a.call(ctx, call))
+		}
+	}
+
+	return messages, fmt.Errorf("agent stopped after %d steps without a final answer", a.MaxSteps)
+}
+
+// call runs one tool call and returns the message reporting its result.
+// Failures are reported to the model so that it can recover.
+func (a *Agent) call(ctx context.Context, call ToolCall) Message {
+	result := func(content string, isError bool) Message {
+		return Message{
+			Role:       RoleTool,
+			Content:    content,
+			ToolResult: &ToolResult{CallID: call.ID, Name: call.Name, IsError: isError},
+		}
+	}
+
+	tool, ok := a.tools.Get(call.Name)
+	if !ok {
+		return result(fmt.Sprintf("unknown tool %q", call.Name), true)
+	}
+	if len(call.Input) == 0 {
+		call.Input = json.RawMessage("{}")
+	}
+
+	if !tool.ReadOnly() {
+		if a.approve == nil {
+			return result("not run: tool calls that change state are not allowed", true)
+		}
+		if err := a.approve(call, tool); err != nil {
+			return result(fmt.Sprintf("not run: %v", err), true)
+		}
+	}
+
+	if a.OnToolCall != nil {
+		a.OnToolCall(call)
+	}
+	output, err := tool.Call(ctx, call.Input)
+	if err != nil {
+		return result(err.Error(), true)
+	}
+	return result(output, false)
+}
+{%- else %}
+package ai
+{%- endif %}
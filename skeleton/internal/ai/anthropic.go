@@ -0,0 +1,246 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+func init() {
+	Register("anthropic", "claude-3-5-sonnet-20241022", newAnthropic)
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	client *anthropic.Client
+}
+
+func newAnthropic(cfg config.AIConfig) (Provider, error) {
+	// Retries are left to the shared HTTP client
+	opts := []option.RequestOption{
+		option.WithHTTPClient(httpClient(cfg)),
+		option.WithMaxRetries(0),
+	}
+	apiKey, err := config.Resolve(cfg.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("ai.api_key: %w", err)
+	}
+	if apiKey != "" {
+		// Without an explicit key the SDK reads ANTHROPIC_API_KEY
+		opts = append(opts, option.WithAPIKey(apiKey))
+	}
+	return &anthropicProvider{client: anthropic.NewClient(opts...)}, nil
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req Request) (Response, error) {
+	params := anthropicParams(req)
+	if len(req.Tools) > 0 {
+		tools := make([]anthropic.ToolParam, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			tools = append(tools, anthropic.ToolParam{
+				Name:        anthropic.F(tool.Name()),
+				Description: anthropic.F(tool.Description()),
+				InputSchema: anthropic.F[any](tool.Schema()),
+			})
+		}
+		params.Tools = anthropic.F(tools)
+	}
+
+	resp, err := p.client.Messages.New(ctx, params)
+	if err != nil {
+		return Response{}, fmt.Errorf("anthropic chat failed: %w", anthropicError(err))
+	}
+
+	result := Response{
+		Usage: Usage{
+			InputTokens:  int(resp.Usage.InputTokens),
+			OutputTokens: int(resp.Usage.OutputTokens),
+		},
+		StopReason: string(resp.StopReason),
+	}
+	for _, block := range resp.Content {
+		switch block := block.AsUnion().(type) {
+		case anthropic.TextBlock:
+			result.Text += block.Text
+		case anthropic.ToolUseBlock:
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:    block.ID,
+				Name:  block.Name,
+				Input: block.Input,
+			})
+		}
+	}
+	if result.Text == "" && len(result.ToolCalls) == 0 {
+		return Response{}, fmt.Errorf("no response from model")
+	}
+	return result, nil
+}
+
+func (p *anthropicProvider) Stream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	stream := p.client.Messages.NewStreaming(ctx, anthropicParams(req))
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+		defer stream.Close()
+
+		last := Chunk{Usage: &Usage{}}
+		for stream.Next() {
+			event := stream.Current()
+			switch event.Type {
+			case anthropic.MessageStreamEventTypeMessageStart:
+				last.Usage.InputTokens = int(event.Message.Usage.InputTokens)
+			case anthropic.MessageStreamEventTypeMessageDelta:
+				// Output tokens are cumulative
+				last.Usage.OutputTokens = int(event.Usage.OutputTokens)
+				if delta, ok := event.Delta.(anthropic.MessageDeltaEventDelta); ok {
+					last.StopReason = string(delta.StopReason)
+				}
+			}
+
+			delta, ok := event.Delta.(anthropic.ContentBlockDeltaEventDelta)
+			if !ok || delta.Text == "" {
+				continue
+			}
+			if !send(ctx, chunks, Chunk{Text: delta.Text}) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil {
+			send(ctx, chunks, Chunk{Err: fmt.Errorf("anthropic chat stream failed: %w", anthropicError(err))})
+			return
+		}
+		send(ctx, chunks, last)
+	}()
+
+	return chunks, nil
+}
+
+func (p *anthropicProvider) ListModels(ctx context.Context) ([]Model, error) {
+	// This SDK version has no models service, so call the endpoint directly
+	var page struct {
+		Data []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}
+	if err := p.client.Get(ctx, "v1/models", nil, &page); err != nil {
+		return nil, fmt.Errorf("anthropic list models failed: %w", anthropicError(err))
+	}
+
+	models := make([]Model, 0, len(page.Data))
+	for _, m := range page.Data {
+		models = append(models, Model{
+			ID:               m.ID,
+			Name:             m.DisplayName,
+			Provider:         "anthropic",
+			ContextWindow:    ContextWindow(m.ID),
+			InputModalities:  []string{ModalityText, ModalityImage},
+			OutputModalities: []string{ModalityText},
+		})
+	}
+	return models, nil
+}
+
+// anthropicParams builds a Messages API request from a provider request
+func anthropicParams(req Request) anthropic.MessageNewParams {
+	var msgs []anthropic.MessageParam
+	var system []anthropic.TextBlockParam
+	// Consecutive tool results are sent together in one user message
+	var results []anthropic.ContentBlockParamUnion
+	for _, m := range req.Messages {
+		if m.Role == RoleTool && m.ToolResult != nil {
+			results = append(results, anthropic.NewToolResultBlock(m.ToolResult.CallID, m.Content, m.ToolResult.IsError))
+			continue
+		}
+		if len(results) > 0 {
+			msgs = append(msgs, anthropic.NewUserMessage(results...))
+			results = nil
+		}
+
+		switch m.Role {
+		case RoleSystem:
+			system = append(system, anthropic.NewTextBlock(m.Content))
+		case RoleAssistant:
+			var blocks []anthropic.ContentBlockParamUnion
+			if m.Content != "" {
+				blocks = append(blocks, anthropic.NewTextBlock(m.Content))
+			}
+			for _, call := range m.ToolCalls {
+				blocks = append(blocks, anthropic.NewToolUseBlockParam(call.ID, call.Name, toolInput(call.Input)))
+			}
+			msgs = append(msgs, anthropic.NewAssistantMessage(blocks...))
+		default:
+			// Attachments go first, as Anthropic recommends
+			blocks := make([]anthropic.ContentBlockParamUnion, 0, len(m.Attachments)+1)
+			for _, a := range m.Attachments {
+				blocks = append(blocks, anthropicAttachment(a))
+			}
+			blocks = append(blocks, anthropic.NewTextBlock(m.Content))
+			msgs = append(msgs, anthropic.NewUserMessage(blocks...))
+		}
+	}
+	if len(results) > 0 {
+		msgs = append(msgs, anthropic.NewUserMessage(results...))
+	}
+
+	// The Messages API requires an output limit
+	maxTokens := req.Options.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.F(req.Model),
+		Messages:  anthropic.F(msgs),
+		MaxTokens: anthropic.Int(int64(maxTokens)),
+	}
+	if len(system) > 0 {
+		params.System = anthropic.F(system)
+	}
+	if req.Options.Temperature != nil {
+		params.Temperature = anthropic.F(*req.Options.Temperature)
+	}
+	if req.Options.TopP != nil {
+		params.TopP = anthropic.F(*req.Options.TopP)
+	}
+	if len(req.Options.Stop) > 0 {
+		params.StopSequences = anthropic.F(req.Options.Stop)
+	}
+	return params
+}
+
+// anthropicAttachment converts an attachment to an image or document
+// block. This SDK version has no document block type, so the generic block
+// is used for PDFs.
+func anthropicAttachment(a Attachment) anthropic.ContentBlockParamUnion {
+	if a.IsImage() {
+		return anthropic.NewImageBlockBase64(a.MIMEType, a.base64())
+	}
+	return anthropic.ContentBlockParam{
+		Type: anthropic.F(anthropic.ContentBlockParamType("document")),
+		Source: anthropic.F[any](map[string]string{
+			"type":       "base64",
+			"media_type": a.MIMEType,
+			"data":       a.base64(),
+		}),
+	}
+}
+
+// anthropicError classifies an Anthropic API error
+func anthropicError(err error) error {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return classify(err, apiErr.StatusCode, apiErr.Error())
+	}
+	return err
+}
+{%- else %}
+package ai
+{%- endif %}
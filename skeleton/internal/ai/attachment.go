@@ -0,0 +1,113 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Attachment size limits, the smallest any provider accepts so that an
+// attachment works with every provider
+const (
+	MaxImageSize    = 3840 << 10 // 3.75 MB
+	MaxDocumentSize = 4608 << 10 // 4.5 MB
+)
+
+// MIME types of the attachments models can read
+const (
+	MIMETypePNG  = "image/png"
+	MIMETypeJPEG = "image/jpeg"
+	MIMETypeGIF  = "image/gif"
+	MIMETypeWebP = "image/webp"
+	MIMETypePDF  = "application/pdf"
+)
+
+// attachmentTypes maps each supported MIME type to its size limit
+var attachmentTypes = map[string]int64{
+	MIMETypePNG:  MaxImageSize,
+	MIMETypeJPEG: MaxImageSize,
+	MIMETypeGIF:  MaxImageSize,
+	MIMETypeWebP: MaxImageSize,
+	MIMETypePDF:  MaxDocumentSize,
+}
+
+// Attachment is an image or document sent to the model with a message
+type Attachment struct {
+	Name     string `json:"name" yaml:"name"`
+	MIMEType string `json:"mime_type" yaml:"mime_type"`
+	Data     []byte `json:"data" yaml:"-"`
+}
+
+// LoadAttachment reads an image or PDF. The type is detected from the
+// content, not the file extension.
+func LoadAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if info.Size() > MaxDocumentSize {
+		return Attachment{}, fmt.Errorf("attachment %s is too large (%s, limit %s)", path, formatSize(info.Size()), formatSize(MaxDocumentSize))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read attachment: %w", err)
+	}
+
+	mimeType := detectMIMEType(data)
+	limit, ok := attachmentTypes[mimeType]
+	if !ok {
+		return Attachment{}, fmt.Errorf("attachment %s has unsupported type %s (supported: PNG, JPEG, GIF and WebP images and PDF documents)", path, mimeType)
+	}
+	if int64(len(data)) > limit {
+		return Attachment{}, fmt.Errorf("attachment %s is too large (%s, limit %s for %s)", path, formatSize(int64(len(data))), formatSize(limit), mimeType)
+	}
+
+	return Attachment{Name: filepath.Base(path), MIMEType: mimeType, Data: data}, nil
+}
+
+// IsImage reports whether the attachment is an image
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MIMEType, "image/")
+}
+
+// base64 returns the data base64-encoded, as most APIs expect it
+func (a Attachment) base64() string {
+	return base64.StdEncoding.EncodeToString(a.Data)
+}
+
+// dataURL returns the attachment as a data: URL
+func (a Attachment) dataURL() string {
+	return "data:" + a.MIMEType + ";base64," + a.base64()
+}
+
+// detectMIMEType sniffs the content type rather than trusting the file
+// extension
+func detectMIMEType(data []byte) string {
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	return mimeType
+}
+
+// imagesOnly returns an error if a request carries a document, for
+// providers whose API only accepts image attachments
+func imagesOnly(provider string, req Request) error {
+	for _, m := range req.Messages {
+		for _, a := range m.Attachments {
+			if !a.IsImage() {
+				return fmt.Errorf("%s does not accept %s attachments such as %s; only images (select another provider with --provider)", provider, a.MIMEType, a.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func formatSize(bytes int64) string {
+	return fmt.Sprintf("%.2f MB", float64(bytes)/(1<<20))
+}
+{%- else %}
+package ai
+{%- endif %}
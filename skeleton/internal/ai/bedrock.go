@@ -0,0 +1,376 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrock"
+	bedrocktypes "github.com/aws/aws-sdk-go-v2/service/bedrock/types"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+func init() {
+	Register("bedrock", "anthropic.claude-3-sonnet-20240229-v1:0", newBedrock)
+}
+
+// bedrockProvider talks to AWS Bedrock through the Converse API
+type bedrockProvider struct {
+	runtime *bedrockruntime.Client
+	catalog *bedrock.Client
+}
+
+func newBedrock(cfg config.AIConfig) (Provider, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithHTTPClient(httpClient(cfg)),
+		// Retries are left to the shared HTTP client
+		awsconfig.WithRetryMaxAttempts(1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	return &bedrockProvider{
+		runtime: bedrockruntime.NewFromConfig(awsCfg),
+		catalog: bedrock.NewFromConfig(awsCfg),
+	}, nil
+}
+
+func (p *bedrockProvider) Chat(ctx context.Context, req Request) (Response, error) {
+	msgs, system := bedrockMessages(req.Messages)
+	output, err := p.runtime.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId:         aws.String(req.Model),
+		Messages:        msgs,
+		System:          system,
+		InferenceConfig: bedrockInference(req.Options),
+		ToolConfig:      bedrockTools(req.Tools),
+	})
+	if err != nil {
+		return Response{}, fmt.Errorf("bedrock converse failed: %w", bedrockError(err))
+	}
+
+	msg, ok := output.Output.(*types.ConverseOutputMemberMessage)
+	if !ok || len(msg.Value.Content) == 0 {
+		return Response{}, fmt.Errorf("no response from model")
+	}
+
+	result := Response{
+		Usage:      bedrockUsage(output.Usage),
+		StopReason: string(output.StopReason),
+	}
+	for _, block := range msg.Value.Content {
+		switch block := block.(type) {
+		case *types.ContentBlockMemberText:
+			result.Text += block.Value
+		case *types.ContentBlockMemberToolUse:
+			input, err := block.Value.Input.MarshalSmithyDocument()
+			if err != nil {
+				return Response{}, fmt.Errorf("bedrock returned invalid tool input: %w", err)
+			}
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:    aws.ToString(block.Value.ToolUseId),
+				Name:  aws.ToString(block.Value.Name),
+				Input: input,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (p *bedrockProvider) Stream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	msgs, system := bedrockMessages(req.Messages)
+	output, err := p.runtime.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+		ModelId:         aws.String(req.Model),
+		Messages:        msgs,
+		System:          system,
+		InferenceConfig: bedrockInference(req.Options),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock converse stream failed: %w", bedrockError(err))
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+		stream := output.GetStream()
+		defer stream.Close()
+
+		var last Chunk
+		for event := range stream.Events() {
+			switch event := event.(type) {
+			case *types.ConverseStreamOutputMemberContentBlockDelta:
+				if text, ok := event.Value.Delta.(*types.ContentBlockDeltaMemberText); ok {
+					if !send(ctx, chunks, Chunk{Text: text.Value}) {
+						return
+					}
+				}
+			case *types.ConverseStreamOutputMemberMessageStop:
+				last.StopReason = string(event.Value.StopReason)
+			case *types.ConverseStreamOutputMemberMetadata:
+				usage := bedrockUsage(event.Value.Usage)
+				last.Usage = &usage
+			}
+		}
+		if err := stream.Err(); err != nil {
+			send(ctx, chunks, Chunk{Err: fmt.Errorf("bedrock converse stream failed: %w", bedrockError(err))})
+			return
+		}
+		send(ctx, chunks, last)
+	}()
+
+	return chunks, nil
+}
+
+func (p *bedrockProvider) ListModels(ctx context.Context) ([]Model, error) {
+	output, err := p.catalog.ListFoundationModels(ctx, &bedrock.ListFoundationModelsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("bedrock list models failed: %w", bedrockError(err))
+	}
+
+	models := make([]Model, 0, len(output.ModelSummaries))
+	for _, m := range output.ModelSummaries {
+		models = append(models, Model{
+			ID:               aws.ToString(m.ModelId),
+			Name:             aws.ToString(m.ModelName),
+			Provider:         "bedrock",
+			ContextWindow:    ContextWindow(aws.ToString(m.ModelId)),
+			InputModalities:  bedrockModalities(m.InputModalities),
+			OutputModalities: bedrockModalities(m.OutputModalities),
+		})
+	}
+	return models, nil
+}
+
+// Embed supports the Amazon Titan text embedding models, which take one
+// text per request
+func (p *bedrockProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, Usage, error) {
+	vectors := make([][]float32, 0, len(texts))
+	var usage Usage
+	for _, text := range texts {
+		body, err := json.Marshal(map[string]any{"inputText": text})
+		if err != nil {
+			return nil, Usage{}, err
+		}
+		output, err := p.runtime.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(model),
+			ContentType: aws.String("application/json"),
+			Accept:      aws.String("application/json"),
+			Body:        body,
+		})
+		if err != nil {
+			return nil, Usage{}, fmt.Errorf("bedrock embeddings failed: %w", bedrockError(err))
+		}
+
+		var result struct {
+			Embedding           []float32 `json:"embedding"`
+			InputTextTokenCount int       `json:"inputTextTokenCount"`
+		}
+		if err := json.Unmarshal(output.Body, &result); err != nil {
+			return nil, Usage{}, fmt.Errorf("invalid embedding response from %s: %w", model, err)
+		}
+		vectors = append(vectors, result.Embedding)
+		usage.InputTokens += result.InputTextTokenCount
+	}
+	return vectors, usage, nil
+}
+
+func (p *bedrockProvider) DefaultEmbeddingModel() string {
+	return "amazon.titan-embed-text-v2:0"
+}
+
+// bedrockModalities converts catalog modalities to lowercase names
+func bedrockModalities(modalities []bedrocktypes.ModelModality) []string {
+	names := make([]string, 0, len(modalities))
+	for _, m := range modalities {
+		names = append(names, strings.ToLower(string(m)))
+	}
+	return names
+}
+
+// bedrockInference maps generation options onto Bedrock's inference settings
+func bedrockInference(opts GenerationOptions) *types.InferenceConfiguration {
+	inference := &types.InferenceConfiguration{StopSequences: opts.Stop}
+	if opts.MaxTokens > 0 {
+		inference.MaxTokens = aws.Int32(int32(opts.MaxTokens))
+	}
+	if opts.Temperature != nil {
+		inference.Temperature = aws.Float32(float32(*opts.Temperature))
+	}
+	if opts.TopP != nil {
+		inference.TopP = aws.Float32(float32(*opts.TopP))
+	}
+	return inference
+}
+
+// bedrockUsage converts Bedrock token usage, which may be missing
+func bedrockUsage(usage *types.TokenUsage) Usage {
+	if usage == nil {
+		return Usage{}
+	}
+	return Usage{
+		InputTokens:  int(aws.ToInt32(usage.InputTokens)),
+		OutputTokens: int(aws.ToInt32(usage.OutputTokens)),
+	}
+}
+
+// bedrockTools describes tools to the Converse API, which rejects an
+// empty tool list
+func bedrockTools(tools []Tool) *types.ToolConfiguration {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	config := &types.ToolConfiguration{}
+	for _, tool := range tools {
+		config.Tools = append(config.Tools, &types.ToolMemberToolSpec{
+			Value: types.ToolSpecification{
+				Name:        aws.String(tool.Name()),
+				Description: aws.String(tool.Description()),
+				InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(tool.Schema())},
+			},
+		})
+	}
+	return config
+}
+
+// bedrockMessages splits a conversation into Bedrock messages and system prompts
+func bedrockMessages(messages []Message) ([]types.Message, []types.SystemContentBlock) {
+	var msgs []types.Message
+	var system []types.SystemContentBlock
+	// Consecutive tool results are sent together in one user message
+	var results []types.ContentBlock
+	for _, m := range messages {
+		if m.Role == RoleTool && m.ToolResult != nil {
+			status := types.ToolResultStatusSuccess
+			if m.ToolResult.IsError {
+				status = types.ToolResultStatusError
+			}
+			results = append(results, &types.ContentBlockMemberToolResult{
+				Value: types.ToolResultBlock{
+					ToolUseId: aws.String(m.ToolResult.CallID),
+					Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: m.Content}},
+					Status:    status,
+				},
+			})
+			continue
+		}
+		if len(results) > 0 {
+			msgs = append(msgs, types.Message{Role: types.ConversationRoleUser, Content: results})
+			results = nil
+		}
+
+		switch m.Role {
+		case RoleSystem:
+			system = append(system, &types.SystemContentBlockMemberText{Value: m.Content})
+		case RoleAssistant:
+			var content []types.ContentBlock
+			if m.Content != "" {
+				content = append(content, &types.ContentBlockMemberText{Value: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				content = append(content, &types.ContentBlockMemberToolUse{
+					Value: types.ToolUseBlock{
+						ToolUseId: aws.String(call.ID),
+						Name:      aws.String(call.Name),
+						Input:     document.NewLazyDocument(toolInput(call.Input)),
+					},
+				})
+			}
+			msgs = append(msgs, types.Message{
+				Role:    types.ConversationRoleAssistant,
+				Content: content,
+			})
+		default:
+			content := make([]types.ContentBlock, 0, len(m.Attachments)+1)
+			for i, a := range m.Attachments {
+				content = append(content, bedrockAttachment(a, i))
+			}
+			content = append(content, &types.ContentBlockMemberText{Value: m.Content})
+			msgs = append(msgs, types.Message{
+				Role:    types.ConversationRoleUser,
+				Content: content,
+			})
+		}
+	}
+	if len(results) > 0 {
+		msgs = append(msgs, types.Message{Role: types.ConversationRoleUser, Content: results})
+	}
+	return msgs, system
+}
+
+// bedrockAttachment converts an attachment to an image or document block.
+// The formats are the MIME subtypes, e.g. png and pdf.
+func bedrockAttachment(a Attachment, i int) types.ContentBlock {
+	_, format, _ := strings.Cut(a.MIMEType, "/")
+	if a.IsImage() {
+		return &types.ContentBlockMemberImage{
+			Value: types.ImageBlock{
+				Format: types.ImageFormat(format),
+				Source: &types.ImageSourceMemberBytes{Value: a.Data},
+			},
+		}
+	}
+	return &types.ContentBlockMemberDocument{
+		Value: types.DocumentBlock{
+			Format: types.DocumentFormat(format),
+			Name:   aws.String(bedrockDocumentName(a.Name, i)),
+			Source: &types.DocumentSourceMemberBytes{Value: a.Data},
+		},
+	}
+}
+
+// bedrockDocumentName makes a document name Bedrock accepts: letters,
+// digits, single spaces, hyphens, parentheses and square brackets, unique
+// within the message
+func bedrockDocumentName(name string, i int) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), strings.ContainsRune("-()[]", r):
+			return r
+		default:
+			return ' '
+		}
+	}, name)
+	return fmt.Sprintf("%s (%d)", strings.Join(strings.Fields(name), " "), i+1)
+}
+
+// bedrockError classifies an AWS error. Bedrock reports some kinds with an
+// exception type rather than a distinct status.
+func bedrockError(err error) error {
+	var signingErr *v4.SigningError
+	if errors.As(err, &signingErr) {
+		// No usable AWS credentials were found
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("%w: %w", ErrModelNotFound, err)
+	}
+	var invalid *types.ValidationException
+	if errors.As(err, &invalid) && strings.Contains(invalid.ErrorMessage(), "model identifier is invalid") {
+		return fmt.Errorf("%w: %w", ErrModelNotFound, err)
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return classify(err, respErr.HTTPStatusCode(), respErr.Error())
+	}
+	return err
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,237 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// Cache defaults used when ai.cache leaves them unset
+const (
+	DefaultCacheTTL     = 7 * 24 * time.Hour
+	DefaultCacheMaxSize = 100 << 20
+)
+
+// ErrCacheMiss is returned in cache-only mode when a request has no cached
+// response
+var ErrCacheMiss = errors.New("no cached response for this request")
+
+// Cache is an on-disk, content-addressed store of provider responses. An
+// entry is keyed by the hash of everything that determines a response: the
+// provider, model, generation options, messages and tools.
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+}
+
+// CacheStats describes the contents of a cache
+type CacheStats struct {
+	Dir     string `json:"dir" yaml:"dir"`
+	Entries int    `json:"entries" yaml:"entries"`
+	Bytes   int64  `json:"bytes" yaml:"bytes"`
+}
+
+// cacheEntry is the stored form of a response
+type cacheEntry struct {
+	Created  time.Time `json:"created"`
+	Response Response  `json:"response"`
+}
+
+// NewCache creates a cache in dir. Entries older than ttl are ignored, and
+// the oldest entries are evicted once the cache exceeds maxSize bytes.
+func NewCache(dir string, ttl time.Duration, maxSize int64) *Cache {
+	return &Cache{dir: dir, ttl: ttl, maxSize: maxSize}
+}
+
+// DefaultCache returns the cache in the config directory, with the TTL and
+// size cap from cfg
+func DefaultCache(cfg config.CacheConfig) (*Cache, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := DefaultCacheTTL
+	if cfg.TTLHours > 0 {
+		ttl = time.Duration(cfg.TTLHours) * time.Hour
+	}
+	maxSize := int64(DefaultCacheMaxSize)
+	if cfg.MaxSizeMB > 0 {
+		maxSize = int64(cfg.MaxSizeMB) << 20
+	}
+	return NewCache(filepath.Join(dir, "cache"), ttl, maxSize), nil
+}
+
+// cacheKey hashes the parts of a request that determine the response
+func cacheKey(provider string, req Request) (string, error) {
+	type toolKey struct {
+		Name        string
+		Description string
+		Schema      map[string]any
+	}
+	key := struct {
+		Provider string
+		Model    string
+		Options  GenerationOptions
+		Messages []Message
+		Tools    []toolKey
+	}{
+		Provider: provider,
+		Model:    req.Model,
+		Options:  req.Options,
+		Messages: req.Messages,
+	}
+	for _, t := range req.Tools {
+		key.Tools = append(key.Tools, toolKey{t.Name(), t.Description(), t.Schema()})
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the response stored under key, if there is one that has not
+// expired
+func (c *Cache) Get(key string) (Response, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return Response{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || time.Since(entry.Created) > c.ttl {
+		_ = os.Remove(c.path(key))
+		return Response{}, false
+	}
+	return entry.Response, true
+}
+
+// Put stores a response under key, evicting the oldest entries if the cache
+// grows beyond its size cap
+func (c *Cache) Put(key string, resp Response) error {
+	data, err := json.Marshal(cacheEntry{Created: time.Now().UTC(), Response: resp})
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	// Write to a temporary file first so readers never see a partial entry
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	return c.evict()
+}
+
+// Stats counts the entries in the cache and their size
+func (c *Cache) Stats() (CacheStats, error) {
+	stats := CacheStats{Dir: c.dir}
+	files, err := c.files()
+	for _, f := range files {
+		stats.Entries++
+		stats.Bytes += f.size
+	}
+	return stats, err
+}
+
+// Clear removes every entry and returns how many there were
+func (c *Cache) Clear() (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(c.dir); err != nil {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return len(files), nil
+}
+
+// cacheFile is an entry on disk
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// files lists the cache entries
+func (c *Cache) files() ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, cacheFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+	return files, nil
+}
+
+// evict removes the oldest entries until the cache fits its size cap
+func (c *Cache) evict() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	if total <= c.maxSize {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+	return nil
+}
+
+// path returns the file for key, sharded by the first byte of the hash
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,94 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkSize returns how many characters of input fit in one request,
+// leaving half the context window for the instructions and the reply
+func (c *Client) chunkSize() int {
+	return c.ContextWindow() / 2 * charsPerToken
+}
+
+// mapReduce applies the named prompt to text, splitting it into chunks when
+// it is too large for one request. The partial results are then combined
+// with the combine prompt, repeatedly if needed, until they fit. Both
+// prompts take the text as .Text. Attachments are sent with every chunk but
+// not when combining.
+func (c *Client) mapReduce(ctx context.Context, text, prompt, combine string, attachments []Attachment) (string, error) {
+	size := c.chunkSize()
+	chunks := splitText(text, size)
+	if len(chunks) == 1 {
+		return c.chatPrompt(ctx, prompt, text, attachments)
+	}
+
+	partials := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		partial, err := c.chatPrompt(ctx, prompt, chunk, attachments)
+		if err != nil {
+			return "", fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+		}
+		partials = append(partials, partial)
+	}
+
+	combined := strings.Join(partials, "\n\n---\n\n")
+	if len(combined) >= len(text) {
+		return "", fmt.Errorf("input of %d characters could not be reduced to fit the model's context window", len(text))
+	}
+	return c.mapReduce(ctx, combined, combine, combine, nil)
+}
+
+// chatPrompt renders the named prompt with text and sends it with the
+// attachments
+func (c *Client) chatPrompt(ctx context.Context, name, text string, attachments []Attachment) (string, error) {
+	prompt, err := c.prompts.Render(name, map[string]string{"Text": text})
+	if err != nil {
+		return "", err
+	}
+	return c.Converse(ctx, []Message{
+		{Role: RoleUser, Content: prompt, Attachments: attachments},
+	})
+}
+
+// splitText splits text into chunks of at most size characters, breaking
+// at line boundaries where possible
+func splitText(text string, size int) []string {
+	if len(text) <= size {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		// Lines longer than a chunk are split wherever they reach the limit
+		for len(line) > size {
+			if current.Len() > 0 {
+				chunks = append(chunks, current.String())
+				current.Reset()
+			}
+			// Back off to a rune boundary so characters are not split
+			cut := size
+			for cut > 1 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			chunks = append(chunks, line[:cut])
+			line = line[cut:]
+		}
+		if current.Len()+len(line) > size {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -4,182 +4,390 @@ package ai
 
 import (
 	"context"
-	"fmt"
+	"strings"
+	"time"
 
 	"github.com/fast-ish/${{values.name}}/internal/config"
-{%- if values.aiProvider == "bedrock" %}
-	"github.com/aws/aws-sdk-go-v2/aws"
-	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
-	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
-{%- elif values.aiProvider == "openai" %}
-	"github.com/sashabaranov/go-openai"
-{%- elif values.aiProvider == "anthropic" %}
-	"github.com/anthropics/anthropic-sdk-go"
-{%- elif values.aiProvider == "ollama" %}
-	"github.com/ollama/ollama/api"
-{%- endif %}
 )
 
-// Client provides AI operations
+// Client provides AI operations on top of the configured provider
 type Client struct {
-	cfg config.AIConfig
-{%- if values.aiProvider == "bedrock" %}
-	bedrock *bedrockruntime.Client
-{%- elif values.aiProvider == "openai" %}
-	openai *openai.Client
-{%- elif values.aiProvider == "anthropic" %}
-	anthropic *anthropic.Client
-{%- elif values.aiProvider == "ollama" %}
-	ollama *api.Client
-{%- endif %}
+	cfg      config.AIConfig
+	provider Provider
+	opts     GenerationOptions
+	ledger   *Ledger
+	recorder *Recorder
+
+	cache     *Cache
+	cacheOnly bool
+
+	prompts *PromptLibrary
 }
 
-// NewClient creates a new AI client
-func NewClient(cfg config.AIConfig) *Client {
-	client := &Client{cfg: cfg}
-	client.init()
-	return client
+// Chunk is an incremental piece of a streamed completion. A chunk with a
+// non-nil Err is always the last value sent before the channel is closed.
+// The last chunk of a successful stream may carry Usage and StopReason
+// instead of text.
+type Chunk struct {
+	Text       string
+	Err        error
+	Usage      *Usage
+	StopReason string
 }
 
-func (c *Client) init() {
-{%- if values.aiProvider == "bedrock" %}
-	cfg, err := awsconfig.LoadDefaultConfig(context.Background(),
-		awsconfig.WithRegion(c.cfg.Region),
-	)
-	if err != nil {
-		panic(fmt.Errorf("failed to load AWS config: %w", err))
-	}
-	c.bedrock = bedrockruntime.NewFromConfig(cfg)
-{%- elif values.aiProvider == "openai" %}
-	c.openai = openai.NewClient(c.cfg.APIKey)
-{%- elif values.aiProvider == "anthropic" %}
-	c.anthropic = anthropic.NewClient(
-		anthropic.WithAPIKey(c.cfg.APIKey),
-	)
-{%- elif values.aiProvider == "ollama" %}
-	client, err := api.ClientFromEnvironment()
+// NewClient creates a new AI client for the provider named in cfg.Provider.
+// It returns an *InitError if the provider cannot be set up.
+func NewClient(cfg config.AIConfig) (*Client, error) {
+	provider, err := NewProvider(cfg)
 	if err != nil {
-		panic(fmt.Errorf("failed to create Ollama client: %w", err))
+		return nil, &InitError{Provider: cfg.Provider, Err: err}
 	}
-	c.ollama = client
-{%- endif %}
+	return NewClientWithProvider(cfg, provider), nil
+}
+
+// NewClientWithProvider creates a client for an existing provider, e.g. a
+// mock in tests. cfg.Provider only names it in usage records and the cache.
+func NewClientWithProvider(cfg config.AIConfig, provider Provider) *Client {
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel(cfg.Provider)
+	}
+	return &Client{
+		cfg:      cfg,
+		provider: provider,
+		opts:     OptionsFromConfig(cfg),
+		prompts:  BuiltinPrompts(),
+	}
+}
+
+// Provider returns the name of the active provider
+func (c *Client) Provider() string {
+	return c.cfg.Provider
+}
+
+// Model returns the model used for requests
+func (c *Client) Model() string {
+	return c.cfg.Model
+}
+
+// SetModel switches the model used for subsequent requests
+func (c *Client) SetModel(model string) {
+	c.cfg.Model = model
+}
+
+// Options returns the generation options used for requests
+func (c *Client) Options() GenerationOptions {
+	return c.opts
+}
+
+// SetOptions applies the options set in opts to subsequent requests
+func (c *Client) SetOptions(opts GenerationOptions) {
+	c.opts = c.opts.Merge(opts)
+}
+
+// SetLedger records the usage of every subsequent request in ledger
+func (c *Client) SetLedger(ledger *Ledger) {
+	c.ledger = ledger
+}
+
+// SetRecorder records every reply from the provider as a fixture for the
+// fake provider
+func (c *Client) SetRecorder(recorder *Recorder) {
+	c.recorder = recorder
+}
+
+// SetCache serves repeated requests from cache and stores new responses in
+// it. With only set, requests that are not cached fail with ErrCacheMiss
+// instead of reaching the provider.
+func (c *Client) SetCache(cache *Cache, only bool) {
+	c.cache = cache
+	c.cacheOnly = only
+}
+
+// SetPrompts replaces the built-in prompts the client's features use
+func (c *Client) SetPrompts(prompts *PromptLibrary) {
+	c.prompts = prompts
+}
+
+// Prompts returns the prompts the client's features use
+func (c *Client) Prompts() *PromptLibrary {
+	return c.prompts
 }
 
-{%- if "chat" in values.aiFeatures %}
+// ListModels returns the models offered by the active provider
+func (c *Client) ListModels(ctx context.Context) ([]Model, error) {
+	return c.provider.ListModels(ctx)
+}
 
-// Chat sends a chat message and returns the response
+// Chat sends a single chat message and returns the response
 func (c *Client) Chat(ctx context.Context, prompt string) (string, error) {
-{%- if values.aiProvider == "bedrock" %}
-	input := &bedrockruntime.ConverseInput{
-		ModelId: aws.String(c.cfg.Model),
-		Messages: []types.Message{
-			{
-				Role: types.ConversationRoleUser,
-				Content: []types.ContentBlock{
-					&types.ContentBlockMemberText{
-						Value: prompt,
-					},
-				},
-			},
-		},
-	}
-
-	output, err := c.bedrock.Converse(ctx, input)
+	return c.Converse(ctx, []Message{
+		{Role: RoleUser, Content: prompt},
+	})
+}
+
+// ChatStream sends a single chat message and returns a channel that yields
+// the response incrementally
+func (c *Client) ChatStream(ctx context.Context, prompt string) (<-chan Chunk, error) {
+	return c.ConverseStream(ctx, []Message{
+		{Role: RoleUser, Content: prompt},
+	})
+}
+
+// Converse sends a conversation history and returns the assistant's reply
+func (c *Client) Converse(ctx context.Context, messages []Message) (string, error) {
+	resp, err := c.chat(ctx, c.request(messages, c.opts))
 	if err != nil {
-		return "", fmt.Errorf("bedrock converse failed: %w", err)
+		return "", err
 	}
+	return resp.Text, nil
+}
 
-	// Extract response text
-	if len(output.Output.(*types.ConverseOutputMemberMessage).Value.Content) > 0 {
-		if text, ok := output.Output.(*types.ConverseOutputMemberMessage).Value.Content[0].(*types.ContentBlockMemberText); ok {
-			return text.Value, nil
-		}
+// ConverseStream sends a conversation history and returns a channel that
+// yields the reply incrementally. The channel is closed when the reply is
+// complete or the context is cancelled.
+func (c *Client) ConverseStream(ctx context.Context, messages []Message) (<-chan Chunk, error) {
+	return c.stream(ctx, c.request(messages, c.opts))
+}
+
+// chat sends a request, or answers it from the cache, and records its usage
+func (c *Client) chat(ctx context.Context, req Request) (Response, error) {
+	return c.chatChecked(ctx, req, nil)
+}
+
+// chatChecked is chat for replies that must pass check, if set, to be
+// served from or stored in the cache, so that a rejected reply is asked for
+// again rather than replayed until it expires
+func (c *Client) chatChecked(ctx context.Context, req Request, check func(Response) error) (Response, error) {
+	key, cached, ok, err := c.lookup(req)
+	if err != nil {
+		return cached, err
+	}
+	// With only the cache to answer from, even a rejected reply is the answer
+	if ok && (check == nil || c.cacheOnly || check(cached) == nil) {
+		return cached, nil
 	}
 
-	return "", fmt.Errorf("no response from model")
-{%- elif values.aiProvider == "openai" %}
-	resp, err := c.openai.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model: c.cfg.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-	})
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.provider.Chat(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("openai chat failed: %w", err)
+		return Response{}, err
 	}
+	resp.Latency = time.Since(start)
+	c.record(req.Model, resp)
+	if check == nil || check(resp) == nil {
+		c.store(key, resp)
+	}
+	c.recordFixture(req, resp)
+	return resp, nil
+}
 
-	if len(resp.Choices) > 0 {
-		return resp.Choices[0].Message.Content, nil
+// stream sends a streaming request and records its usage once the stream
+// completes
+func (c *Client) stream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	key, cached, ok, err := c.lookup(req)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		// Replay the cached reply as a single chunk
+		replay := make(chan Chunk, 1)
+		replay <- Chunk{Text: cached.Text, StopReason: cached.StopReason}
+		close(replay)
+		return replay, nil
 	}
 
-	return "", fmt.Errorf("no response from model")
-{%- elif values.aiProvider == "anthropic" %}
-	resp, err := c.anthropic.Messages.New(ctx, anthropic.MessageNewParams{
-		Model: anthropic.F(c.cfg.Model),
-		Messages: anthropic.F([]anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
-		}),
-		MaxTokens: anthropic.Int(1024),
-	})
+	ctx, cancel := c.withTimeout(ctx)
+
+	start := time.Now()
+	chunks, err := c.provider.Stream(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("anthropic chat failed: %w", err)
+		cancel()
+		return nil, err
 	}
 
-	if len(resp.Content) > 0 {
-		if text, ok := resp.Content[0].AsUnion().(anthropic.TextBlock); ok {
-			return text.Text, nil
+	relay := make(chan Chunk)
+	go func() {
+		defer close(relay)
+		defer cancel()
+
+		var resp Response
+		var text strings.Builder
+		for chunk := range chunks {
+			text.WriteString(chunk.Text)
+			if chunk.Usage != nil {
+				resp.Usage = *chunk.Usage
+			}
+			if chunk.StopReason != "" {
+				resp.StopReason = chunk.StopReason
+			}
+			if !send(ctx, relay, chunk) {
+				return
+			}
+			if chunk.Err != nil {
+				return
+			}
+		}
+		resp.Latency = time.Since(start)
+		c.record(req.Model, resp)
+		if ctx.Err() == nil {
+			resp.Text = text.String()
+			c.store(key, resp)
+			c.recordFixture(req, resp)
 		}
+	}()
+
+	return relay, nil
+}
+
+// lookup returns the cached response to req, if there is a cache and it
+// holds one. The key to store a new response under is returned either way.
+func (c *Client) lookup(req Request) (key string, resp Response, ok bool, err error) {
+	if c.cache == nil {
+		return "", Response{}, false, nil
+	}
+	key, err = cacheKey(c.cfg.Provider, req)
+	if err != nil {
+		return "", Response{}, false, err
+	}
+	if resp, ok = c.cache.Get(key); ok {
+		return key, resp, true, nil
+	}
+	if c.cacheOnly {
+		return key, Response{}, false, ErrCacheMiss
 	}
+	return key, Response{}, false, nil
+}
 
-	return "", fmt.Errorf("no response from model")
-{%- elif values.aiProvider == "ollama" %}
-	req := &api.GenerateRequest{
-		Model:  c.cfg.Model,
-		Prompt: prompt,
+// store caches a response, if there is a cache. A failed write only costs a
+// later cache miss, so it is ignored.
+func (c *Client) store(key string, resp Response) {
+	if c.cache == nil {
+		return
 	}
+	_ = c.cache.Put(key, resp)
+}
 
-	var response string
-	err := c.ollama.Generate(ctx, req, func(resp api.GenerateResponse) error {
-		response += resp.Response
-		return nil
-	})
-	if err != nil {
-		return "", fmt.Errorf("ollama generate failed: %w", err)
+// recordFixture records a reply, if a recorder is set. Like usage
+// accounting, recording must never fail a request.
+func (c *Client) recordFixture(req Request, resp Response) {
+	if c.recorder == nil {
+		return
 	}
+	_ = c.recorder.Record(req, resp)
+}
 
-	return response, nil
-{%- endif %}
+// withTimeout bounds a call, including its retries, by ai.timeout
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.cfg.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(c.cfg.Timeout)*time.Second)
+}
+
+// record appends the usage of a response from model to the ledger, if one
+// is set. Usage accounting must never fail a request, so write errors are
+// ignored.
+func (c *Client) record(model string, resp Response) {
+	if c.ledger == nil {
+		return
+	}
+	_ = c.ledger.Record(UsageRecord{
+		Time:       time.Now().UTC(),
+		Provider:   c.cfg.Provider,
+		Model:      model,
+		Usage:      resp.Usage,
+		LatencyMS:  resp.Latency.Milliseconds(),
+		StopReason: resp.StopReason,
+	})
+}
+
+// request builds a provider request. The system prompt from opts is added
+// unless the conversation already starts with one.
+func (c *Client) request(messages []Message, opts GenerationOptions) Request {
+	if opts.System != "" && (len(messages) == 0 || messages[0].Role != RoleSystem) {
+		messages = append([]Message{
+			{Role: RoleSystem, Content: opts.System},
+		}, messages...)
+	}
+	return Request{
+		Model:    c.cfg.Model,
+		Messages: messages,
+		Options:  opts,
+	}
 }
-{%- endif %}
 
 {%- if "analyze" in values.aiFeatures %}
 
-// Analyze analyzes text and returns insights
-func (c *Client) Analyze(ctx context.Context, text string) (string, error) {
-	prompt := fmt.Sprintf("Analyze the following text and provide insights:\n\n%s", text)
-	return c.Chat(ctx, prompt)
+// Analyze analyzes text, and any attached images and documents, and
+// returns insights. Text too large for the model's context window is
+// analyzed in parts, each with the attachments, and the results combined.
+func (c *Client) Analyze(ctx context.Context, text string, attachments ...Attachment) (string, error) {
+	return c.mapReduce(ctx, text, analyzePrompt(attachments), "analyze-combine", attachments)
+}
+
+// AnalyzeJSON analyzes text and any attachments, and returns the insights
+// as a value matching schema
+func (c *Client) AnalyzeJSON(ctx context.Context, text string, schema *Schema, attachments ...Attachment) (any, error) {
+	if len(splitText(text, c.chunkSize())) > 1 {
+		// Analyze large text in parts first, then structure the result
+		analysis, err := c.Analyze(ctx, text, attachments...)
+		if err != nil {
+			return nil, err
+		}
+		prompt, err := c.prompts.Render("analyze-json", map[string]string{"Text": analysis})
+		if err != nil {
+			return nil, err
+		}
+		return c.Structured(ctx, prompt, schema, GenerationOptions{})
+	}
+
+	prompt, err := c.prompts.Render(analyzePrompt(attachments), map[string]string{"Text": text})
+	if err != nil {
+		return nil, err
+	}
+	return c.structured(ctx, Message{Role: RoleUser, Content: prompt, Attachments: attachments}, schema, GenerationOptions{})
+}
+
+// analyzePrompt names the prompt for analyzing text with attachments
+func analyzePrompt(attachments []Attachment) string {
+	if len(attachments) > 0 {
+		return "analyze-attachments"
+	}
+	return "analyze"
 }
 {%- endif %}
 
 {%- if "summarize" in values.aiFeatures %}
 
-// Summarize creates a summary of the given text
+// Summarize creates a summary of the given text. Text too large for the
+// model's context window is summarized in parts, then the part summaries
+// are summarized together (map-reduce).
 func (c *Client) Summarize(ctx context.Context, text string) (string, error) {
-	prompt := fmt.Sprintf("Summarize the following text concisely:\n\n%s", text)
-	return c.Chat(ctx, prompt)
+	return c.mapReduce(ctx, text, "summarize", "summarize-combine", nil)
 }
 {%- endif %}
 
 {%- if "generate" in values.aiFeatures %}
 
-// Generate generates content based on a prompt
-func (c *Client) Generate(ctx context.Context, prompt string, options map[string]any) (string, error) {
-	// TODO: Support options like temperature, max_tokens, etc.
-	return c.Chat(ctx, prompt)
+// Generate generates content based on a prompt. Options set in opts take
+// precedence over the client's options for this request only.
+func (c *Client) Generate(ctx context.Context, prompt string, opts GenerationOptions) (string, error) {
+	messages := []Message{
+		{Role: RoleUser, Content: prompt},
+	}
+	resp, err := c.chat(ctx, c.request(messages, c.opts.Merge(opts)))
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// GenerateJSON generates content as a value matching schema
+func (c *Client) GenerateJSON(ctx context.Context, prompt string, schema *Schema, opts GenerationOptions) (any, error) {
+	return c.Structured(ctx, prompt, schema, opts)
 }
 {%- endif %}
 {%- else %}
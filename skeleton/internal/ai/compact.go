@@ -0,0 +1,106 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultKeepTurns is how many recent turns compaction keeps verbatim
+const defaultKeepTurns = 4
+
+// compactRequest introduces the summary that replaces compacted turns. The
+// summary is the assistant's reply to it, so that turns still alternate.
+const compactRequest = "Summarize our conversation so far."
+
+// historyBudget returns how many tokens a conversation may take, leaving
+// room for the reply: max_tokens if set, else a quarter of the window
+func (c *Client) historyBudget() int {
+	window := c.ContextWindow()
+	reserve := window / 4
+	if c.opts.MaxTokens > 0 && c.opts.MaxTokens < window {
+		reserve = c.opts.MaxTokens
+	}
+	return window - reserve
+}
+
+// Compact returns messages shortened to fit the model's context window, and
+// whether they had to be. The system prompt and the most recent turns, up to
+// ai.keep_turns of them, stay verbatim; the older turns are summarized. A
+// turn starts with a user message and includes the replies and tool calls
+// that follow it.
+func (c *Client) Compact(ctx context.Context, messages []Message) ([]Message, bool, error) {
+	budget := c.historyBudget()
+	if c.CountTokens(messages) <= budget {
+		return messages, false, nil
+	}
+
+	head := 0
+	for head < len(messages) && messages[head].Role == RoleSystem {
+		head++
+	}
+
+	// Keep as many recent turns as fit in half the budget, but at least one
+	keep := c.cfg.KeepTurns
+	if keep <= 0 {
+		keep = defaultKeepTurns
+	}
+	split, turns := len(messages), 0
+	for i := len(messages) - 1; i >= head && turns < keep; i-- {
+		if messages[i].Role != RoleUser {
+			continue
+		}
+		if turns > 0 && c.CountTokens(messages[i:]) > budget/2 {
+			break
+		}
+		split, turns = i, turns+1
+	}
+	if split <= head {
+		// Only the latest turn is left, which cannot be summarized
+		return messages, false, nil
+	}
+
+	summary, err := c.mapReduce(ctx, transcript(messages[head:split]), "compact", "summarize-combine", nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to compact the conversation: %w", err)
+	}
+
+	compacted := make([]Message, 0, head+2+len(messages)-split)
+	compacted = append(compacted, messages[:head]...)
+	compacted = append(compacted,
+		Message{Role: RoleUser, Content: compactRequest},
+		Message{Role: RoleAssistant, Content: summary},
+	)
+	compacted = append(compacted, messages[split:]...)
+	return compacted, true, nil
+}
+
+// transcript renders messages as plain text for summarizing
+func transcript(messages []Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		switch m.Role {
+		case RoleUser:
+			b.WriteString("User: ")
+		case RoleAssistant:
+			b.WriteString("Assistant: ")
+		case RoleTool:
+			b.WriteString("Tool result: ")
+		default:
+			b.WriteString("System: ")
+		}
+		b.WriteString(m.Content)
+		for _, a := range m.Attachments {
+			fmt.Fprintf(&b, " [attached %s]", a.Name)
+		}
+		for _, call := range m.ToolCalls {
+			fmt.Fprintf(&b, " [called %s with %s]", call.Name, call.Input)
+		}
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+{%- else %}
+package ai
+{%- endif %}
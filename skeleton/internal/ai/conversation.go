@@ -0,0 +1,56 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+// Role identifies the author of a message in a conversation
+type Role string
+
+// Conversation roles understood by every provider
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	// RoleTool marks the result of a tool call, with the result as Content
+	RoleTool Role = "tool"
+)
+
+// Message is a single turn in a conversation
+type Message struct {
+	Role        Role         `json:"role" yaml:"role"`
+	Content     string       `json:"content" yaml:"content"`
+	Attachments []Attachment `json:"attachments,omitempty" yaml:"attachments,omitempty"` // user only
+	ToolCalls   []ToolCall   `json:"tool_calls,omitempty" yaml:"tool_calls,omitempty"`   // assistant only
+	ToolResult  *ToolResult  `json:"tool_result,omitempty" yaml:"tool_result,omitempty"` // tool only
+}
+
+// Conversation accumulates the turns of a multi-turn chat
+type Conversation struct {
+	Messages []Message `json:"messages" yaml:"messages"`
+}
+
+// NewConversation creates a conversation, optionally seeded with a system prompt
+func NewConversation(system string) *Conversation {
+	conv := &Conversation{}
+	if system != "" {
+		conv.Append(Message{Role: RoleSystem, Content: system})
+	}
+	return conv
+}
+
+// Append adds messages to the end of the conversation
+func (c *Conversation) Append(messages ...Message) {
+	c.Messages = append(c.Messages, messages...)
+}
+
+// Reset discards all turns but keeps the system prompt
+func (c *Conversation) Reset() {
+	kept := c.Messages[:0]
+	for _, m := range c.Messages {
+		if m.Role == RoleSystem {
+			kept = append(kept, m)
+		}
+	}
+	c.Messages = kept
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,75 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// embedBatchSize is the number of texts sent in one embedding request
+const embedBatchSize = 64
+
+// ErrEmbeddingsUnsupported is returned by Embed when the provider offers no
+// embeddings
+var ErrEmbeddingsUnsupported = errors.New("provider does not support embeddings")
+
+// EmbeddingModel returns the model used for embeddings, or "" if the
+// provider offers none
+func (c *Client) EmbeddingModel() string {
+	embedder, ok := c.provider.(Embedder)
+	if !ok {
+		return ""
+	}
+	if c.cfg.EmbeddingModel != "" {
+		return c.cfg.EmbeddingModel
+	}
+	return embedder.DefaultEmbeddingModel()
+}
+
+// Embed returns an embedding vector for each text, in order, computed with
+// the embedding model. Texts are sent in batches.
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder, ok := c.provider.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (select another with --provider)", ErrEmbeddingsUnsupported, c.cfg.Provider)
+	}
+	model := c.EmbeddingModel()
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch := texts[start:min(start+embedBatchSize, len(texts))]
+		batchVectors, err := c.embed(ctx, embedder, model, batch)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batchVectors...)
+	}
+	return vectors, nil
+}
+
+// embed sends one batch and records its usage
+func (c *Client) embed(ctx context.Context, embedder Embedder, model string, texts []string) ([][]float32, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	start := time.Now()
+	vectors, usage, err := embedder.Embed(ctx, model, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings from %s, got %d", len(texts), model, len(vectors))
+	}
+	for _, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("empty embedding from %s", model)
+		}
+	}
+	c.record(model, Response{Usage: usage, Latency: time.Since(start)})
+	return vectors, nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,85 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors that providers' failures are classified as. Match them with
+// errors.Is; the provider's own error stays wrapped for details.
+var (
+	// ErrAuth means the credentials are missing, invalid or lack access
+	ErrAuth = errors.New("authentication failed")
+	// ErrRateLimited means the provider throttled the request, even after
+	// retrying
+	ErrRateLimited = errors.New("rate limited")
+	// ErrModelNotFound means the model does not exist or is not enabled
+	ErrModelNotFound = errors.New("model not found")
+	// ErrContextTooLong means the request exceeds the model's context window
+	ErrContextTooLong = errors.New("context too long")
+)
+
+// InitError is returned when the client for a provider cannot be created,
+// e.g. because the provider is unknown or its configuration is invalid
+type InitError struct {
+	Provider string
+	Err      error
+}
+
+func (e *InitError) Error() string {
+	return fmt.Sprintf("failed to create %s AI client: %v", e.Provider, e.Err)
+}
+
+func (e *InitError) Unwrap() error {
+	return e.Err
+}
+
+// contextTooLongHints are fragments of the messages providers use when a
+// request does not fit the context window
+var contextTooLongHints = []string{
+	"context length",
+	"context_length",
+	"context window",
+	"maximum context",
+	"too many tokens",
+	"too many input tokens",
+	"prompt is too long",
+	"input is too long",
+}
+
+// classify wraps err with the error kind indicated by an HTTP status and
+// the provider's error message. Errors of no known kind are returned as is.
+func classify(err error, status int, message string) error {
+	message = strings.ToLower(message)
+
+	var kind error
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		kind = ErrAuth
+	case status == http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case containsAny(message, contextTooLongHints):
+		kind = ErrContextTooLong
+	case status == http.StatusNotFound && strings.Contains(message, "model"),
+		strings.Contains(message, "model") && strings.Contains(message, "not found"):
+		kind = ErrModelNotFound
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
+{%- else %}
+package ai
+{%- endif %}
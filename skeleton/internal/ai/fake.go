@@ -0,0 +1,305 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+func init() {
+	Register("fake", "fake", newFake)
+}
+
+// fakeEmbeddingSize is the length of the fake provider's vectors
+const fakeEmbeddingSize = 256
+
+// Fixtures is the format of the file the fake provider replays and
+// recording appends to
+type Fixtures struct {
+	Fixtures []Fixture `json:"fixtures"`
+}
+
+// Fixture is a canned reply. It matches a request whose prompt, the content
+// of its last message, matches the regular expression Match or has the
+// SHA-256 hash Hash. A fixture with neither matches every request. The
+// first matching fixture is replayed.
+type Fixture struct {
+	Match string `json:"match,omitempty"`
+	Hash  string `json:"hash,omitempty"`
+	// Prompt is written when recording, for reference; it is not matched
+	Prompt string `json:"prompt,omitempty"`
+
+	Response   string     `json:"response,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	StopReason string     `json:"stop_reason,omitempty"`
+	Usage      *Usage     `json:"usage,omitempty"` // estimated from the text if unset
+
+	// Error fails the request with this message. Status classifies it as a
+	// provider's HTTP status would, e.g. 429 fails with ErrRateLimited.
+	Error  string `json:"error,omitempty"`
+	Status int    `json:"status,omitempty"`
+
+	LatencyMS int `json:"latency_ms,omitempty"` // before the reply starts
+	ChunkMS   int `json:"chunk_ms,omitempty"`   // between streamed words
+
+	re *regexp.Regexp
+}
+
+// fakeProvider replays fixtures instead of calling a model, for tests,
+// demos and offline CI. Without a fixtures file it echoes the prompt.
+type fakeProvider struct {
+	fixtures []Fixture
+	echo     bool
+}
+
+func newFake(cfg config.AIConfig) (Provider, error) {
+	if cfg.Fixtures == "" {
+		return &fakeProvider{echo: true}, nil
+	}
+
+	fixtures, err := LoadFixtures(cfg.Fixtures)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fixtures.Fixtures {
+		f := &fixtures.Fixtures[i]
+		if f.Match == "" {
+			continue
+		}
+		if f.re, err = regexp.Compile(f.Match); err != nil {
+			return nil, fmt.Errorf("invalid match in fixture %d: %w", i+1, err)
+		}
+	}
+	return &fakeProvider{fixtures: fixtures.Fixtures}, nil
+}
+
+// LoadFixtures reads a fixtures file
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("invalid fixtures file %s: %w", path, err)
+	}
+	return &fixtures, nil
+}
+
+// PromptHash returns the hash a fixture matches a prompt by
+func PromptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, req Request) (Response, error) {
+	f, err := p.match(req)
+	if err != nil {
+		return Response{}, err
+	}
+	if err := sleep(ctx, f.LatencyMS); err != nil {
+		return Response{}, err
+	}
+	if f.Error != "" {
+		return Response{}, fmt.Errorf("fake chat failed: %w", classify(errors.New(f.Error), f.Status, f.Error))
+	}
+	return f.response(req), nil
+}
+
+func (p *fakeProvider) Stream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	f, err := p.match(req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+
+		if err := sleep(ctx, f.LatencyMS); err != nil {
+			return
+		}
+		if f.Error != "" {
+			send(ctx, chunks, Chunk{Err: fmt.Errorf("fake chat failed: %w", classify(errors.New(f.Error), f.Status, f.Error))})
+			return
+		}
+
+		resp := f.response(req)
+		for i, word := range strings.SplitAfter(resp.Text, " ") {
+			if i > 0 && sleep(ctx, f.ChunkMS) != nil {
+				return
+			}
+			if !send(ctx, chunks, Chunk{Text: word}) {
+				return
+			}
+		}
+		send(ctx, chunks, Chunk{Usage: &resp.Usage, StopReason: resp.StopReason})
+	}()
+	return chunks, nil
+}
+
+func (p *fakeProvider) ListModels(ctx context.Context) ([]Model, error) {
+	model := Model{
+		ID:               "fake",
+		Name:             "Fake (fixtures)",
+		Provider:         "fake",
+		InputModalities:  []string{ModalityText, ModalityImage},
+		OutputModalities: []string{ModalityText, ModalityEmbedding},
+	}
+	return []Model{model}, nil
+}
+
+// Embed hashes the words of each text into a vector, so that texts sharing
+// words are similar
+func (p *fakeProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, Usage, error) {
+	vectors := make([][]float32, len(texts))
+	var usage Usage
+	for i, text := range texts {
+		v := make([]float32, fakeEmbeddingSize)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			h := fnv.New32a()
+			h.Write([]byte(strings.Trim(word, ".,;:!?\"'()[]")))
+			v[h.Sum32()%fakeEmbeddingSize]++
+		}
+		vectors[i] = v
+		usage.InputTokens += CountTokens(model, text)
+	}
+	return vectors, usage, nil
+}
+
+func (p *fakeProvider) DefaultEmbeddingModel() string {
+	return "fake-embedding"
+}
+
+// match returns the first fixture matching the request's prompt
+func (p *fakeProvider) match(req Request) (Fixture, error) {
+	prompt := lastPrompt(req)
+	if p.echo {
+		return Fixture{Response: "fake reply to: " + prompt}, nil
+	}
+
+	hash := PromptHash(prompt)
+	for _, f := range p.fixtures {
+		switch {
+		case f.re != nil && f.re.MatchString(prompt),
+			f.Hash != "" && f.Hash == hash,
+			f.re == nil && f.Hash == "":
+			return f, nil
+		}
+	}
+
+	preview := prompt
+	if runes := []rune(preview); len(runes) > 80 {
+		preview = string(runes[:80]) + "..."
+	}
+	return Fixture{}, fmt.Errorf("no fixture matches prompt %q (hash %s)", preview, hash)
+}
+
+// response builds the reply a fixture describes
+func (f Fixture) response(req Request) Response {
+	resp := Response{Text: f.Response, ToolCalls: f.ToolCalls, StopReason: f.StopReason}
+	if resp.StopReason == "" {
+		resp.StopReason = "stop"
+	}
+	if f.Usage != nil {
+		resp.Usage = *f.Usage
+	} else {
+		resp.Usage.InputTokens = CountMessageTokens(req.Model, req.Messages)
+		resp.Usage.OutputTokens = CountTokens(req.Model, f.Response)
+	}
+	return resp
+}
+
+// lastPrompt returns the content of the request's last message
+func lastPrompt(req Request) string {
+	if len(req.Messages) == 0 {
+		return ""
+	}
+	return req.Messages[len(req.Messages)-1].Content
+}
+
+// sleep waits for ms milliseconds or until ctx is cancelled
+func sleep(ctx context.Context, ms int) error {
+	if ms <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recorder appends the replies of a real provider to a fixtures file, so
+// that sessions can be replayed offline with the fake provider
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewRecorder creates a recorder that writes to path
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path}
+}
+
+// Record appends a fixture that replays resp for req's prompt
+func (r *Recorder) Record(req Request, resp Response) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The first recording creates the file
+	fixtures, err := LoadFixtures(r.path)
+	if errors.Is(err, os.ErrNotExist) {
+		fixtures, err = &Fixtures{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	prompt := lastPrompt(req)
+	usage := resp.Usage
+	fixtures.Fixtures = append(fixtures.Fixtures, Fixture{
+		Hash:       PromptHash(prompt),
+		Prompt:     prompt,
+		Response:   resp.Text,
+		ToolCalls:  resp.ToolCalls,
+		StopReason: resp.StopReason,
+		Usage:      &usage,
+		LatencyMS:  int(resp.Latency.Milliseconds()),
+	})
+
+	data, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(r.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create fixtures directory: %w", err)
+		}
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write fixtures: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to write fixtures: %w", err)
+	}
+	return nil
+}
+{%- else %}
+package ai
+{%- endif %}
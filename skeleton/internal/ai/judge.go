@@ -0,0 +1,44 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"fmt"
+)
+
+// verdictSchema is the reply Judge asks for
+var verdictSchema = mustCompileSchema("verdict.json", `{
+  "type": "object",
+  "properties": {
+    "pass": {"type": "boolean"},
+    "reason": {"type": "string"}
+  },
+  "required": ["pass", "reason"]
+}`)
+
+// Verdict is a judge model's grade of an output
+type Verdict struct {
+	Pass   bool   `json:"pass" yaml:"pass"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// Judge asks the model whether output, produced for input, meets criteria
+func (c *Client) Judge(ctx context.Context, criteria, input, output string) (*Verdict, error) {
+	prompt, err := c.prompts.Render("judge", map[string]string{"Criteria": criteria, "Input": input, "Text": output})
+	if err != nil {
+		return nil, err
+	}
+	value, err := c.Structured(ctx, prompt, verdictSchema, GenerationOptions{Temperature: Float(0)})
+	if err != nil {
+		return nil, err
+	}
+
+	var v Verdict
+	if err := decode(value, &v); err != nil {
+		return nil, fmt.Errorf("invalid verdict: %w", err)
+	}
+	return &v, nil
+}
+{%- else %}
+package ai
+{%- endif %}
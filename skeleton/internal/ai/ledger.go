@@ -0,0 +1,108 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// UsageRecord is the usage of one AI request
+type UsageRecord struct {
+	Time       time.Time `json:"time"`
+	Provider   string    `json:"provider"`
+	Model      string    `json:"model"`
+	Usage      Usage     `json:"usage"`
+	LatencyMS  int64     `json:"latency_ms"`
+	StopReason string    `json:"stop_reason,omitempty"`
+}
+
+// Ledger is an append-only local log of AI usage, one JSON record per line
+type Ledger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewLedger creates a ledger backed by the file at path
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// DefaultLedger returns the ledger in the config directory
+func DefaultLedger() (*Ledger, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return NewLedger(filepath.Join(dir, "usage.jsonl")), nil
+}
+
+// Path returns the ledger file path
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Record appends a record to the ledger
+func (l *Ledger) Record(r UsageRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create usage ledger directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Records returns the records made at or after since, oldest first. Lines
+// that cannot be parsed are skipped.
+func (l *Ledger) Records(since time.Time) ([]UsageRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if !r.Time.Before(since) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	return records, nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,62 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import "strings"
+
+// Modalities used in model descriptions
+const (
+	ModalityText      = "text"
+	ModalityImage     = "image"
+	ModalityAudio     = "audio"
+	ModalityEmbedding = "embedding"
+)
+
+// contextWindows maps model ID fragments to context window sizes for
+// catalogs that do not report them. The first match wins, so more specific
+// fragments come first.
+var contextWindows = []struct {
+	fragment string
+	tokens   int
+}{
+	{"claude-3", 200000},
+	{"claude", 100000},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"o1", 128000},
+	{"llama3-1", 128000},
+	{"llama3.1", 128000},
+	{"llama3-2", 128000},
+	{"llama3.2", 128000},
+	{"llama3", 8192},
+	{"llama2", 4096},
+	{"mixtral", 32768},
+	{"mistral", 32768},
+	{"titan-text", 8192},
+	{"command-r", 128000},
+}
+
+// ContextWindow returns the known context window of a model in tokens, or 0
+// if it is unknown
+func ContextWindow(model string) int {
+	id := strings.ToLower(model)
+	for _, w := range contextWindows {
+		if strings.Contains(id, w.fragment) {
+			return w.tokens
+		}
+	}
+	return 0
+}
+
+// MatchesFilter reports whether the model's ID or name contains filter,
+// ignoring case. An empty filter matches every model.
+func (m Model) MatchesFilter(filter string) bool {
+	filter = strings.ToLower(filter)
+	return strings.Contains(strings.ToLower(m.ID), filter) ||
+		strings.Contains(strings.ToLower(m.Name), filter)
+}
+{%- else %}
+package ai
+{%- endif %}
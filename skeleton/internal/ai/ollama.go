@@ -0,0 +1,240 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+func init() {
+	Register("ollama", "llama2", newOllama)
+}
+
+// ollamaProvider talks to a local or remote Ollama server
+type ollamaProvider struct {
+	client *api.Client
+}
+
+func newOllama(cfg config.AIConfig) (Provider, error) {
+	// Without ai.host, use OLLAMA_HOST and then the default local address
+	base := envconfig.Host()
+	if cfg.Host != "" {
+		var err error
+		if base, err = url.Parse(cfg.Host); err != nil {
+			return nil, fmt.Errorf("invalid Ollama host %q: %w", cfg.Host, err)
+		}
+	}
+	return &ollamaProvider{client: api.NewClient(base, httpClient(cfg))}, nil
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req Request) (Response, error) {
+	if err := imagesOnly("ollama", req); err != nil {
+		return Response{}, err
+	}
+	request := ollamaRequest(req)
+	for _, tool := range req.Tools {
+		t, err := ollamaTool(tool)
+		if err != nil {
+			return Response{}, err
+		}
+		request.Tools = append(request.Tools, t)
+	}
+
+	var result Response
+	err := p.client.Chat(ctx, request, func(resp api.ChatResponse) error {
+		result.Text += resp.Message.Content
+		if resp.Done {
+			result.Usage = ollamaUsage(resp.Metrics)
+			result.StopReason = resp.DoneReason
+		}
+		for _, call := range resp.Message.ToolCalls {
+			input, err := json.Marshal(call.Function.Arguments)
+			if err != nil {
+				return err
+			}
+			// Ollama does not identify calls, so number them
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:    fmt.Sprintf("call_%d", len(result.ToolCalls)),
+				Name:  call.Function.Name,
+				Input: input,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return Response{}, fmt.Errorf("ollama chat failed: %w", ollamaError(err))
+	}
+
+	return result, nil
+}
+
+func (p *ollamaProvider) Stream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	if err := imagesOnly("ollama", req); err != nil {
+		return nil, err
+	}
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+
+		err := p.client.Chat(ctx, ollamaRequest(req), func(resp api.ChatResponse) error {
+			if resp.Message.Content != "" {
+				if !send(ctx, chunks, Chunk{Text: resp.Message.Content}) {
+					return ctx.Err()
+				}
+			}
+			if resp.Done {
+				usage := ollamaUsage(resp.Metrics)
+				send(ctx, chunks, Chunk{Usage: &usage, StopReason: resp.DoneReason})
+			}
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			send(ctx, chunks, Chunk{Err: fmt.Errorf("ollama chat failed: %w", ollamaError(err))})
+		}
+	}()
+
+	return chunks, nil
+}
+
+func (p *ollamaProvider) ListModels(ctx context.Context) ([]Model, error) {
+	list, err := p.client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ollama list models failed: %w", ollamaError(err))
+	}
+
+	models := make([]Model, 0, len(list.Models))
+	for _, m := range list.Models {
+		model := Model{
+			ID:               m.Model,
+			Name:             m.Name,
+			Provider:         "ollama",
+			ContextWindow:    ContextWindow(m.Model),
+			InputModalities:  []string{ModalityText},
+			OutputModalities: []string{ModalityText},
+		}
+
+		// The list response has no model details, so ask for them per model
+		// and keep the table defaults if the server cannot describe it
+		if info, err := p.client.Show(ctx, &api.ShowRequest{Model: m.Model}); err == nil {
+			if window := ollamaContextLength(info.ModelInfo); window > 0 {
+				model.ContextWindow = window
+			}
+			if len(info.ProjectorInfo) > 0 {
+				model.InputModalities = append(model.InputModalities, ModalityImage)
+			}
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+func (p *ollamaProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, Usage, error) {
+	resp, err := p.client.Embed(ctx, &api.EmbedRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("ollama embed failed: %w", ollamaError(err))
+	}
+	return resp.Embeddings, Usage{InputTokens: resp.PromptEvalCount}, nil
+}
+
+func (p *ollamaProvider) DefaultEmbeddingModel() string {
+	return "nomic-embed-text"
+}
+
+// ollamaUsage converts Ollama's evaluation counts to token usage
+func ollamaUsage(m api.Metrics) Usage {
+	return Usage{
+		InputTokens:  m.PromptEvalCount,
+		OutputTokens: m.EvalCount,
+	}
+}
+
+// ollamaContextLength reads the context length from a model's metadata,
+// which is keyed by architecture, e.g. "llama.context_length"
+func ollamaContextLength(info map[string]any) int {
+	arch, _ := info["general.architecture"].(string)
+	if length, ok := info[arch+".context_length"].(float64); ok {
+		return int(length)
+	}
+	return 0
+}
+
+// ollamaTool describes a tool to Ollama, whose API models only a subset of
+// JSON Schema
+func ollamaTool(tool Tool) (api.Tool, error) {
+	t := api.Tool{Type: "function"}
+	t.Function.Name = tool.Name()
+	t.Function.Description = tool.Description()
+
+	schema, err := json.Marshal(tool.Schema())
+	if err != nil {
+		return api.Tool{}, fmt.Errorf("invalid schema for tool %q: %w", tool.Name(), err)
+	}
+	if err := json.Unmarshal(schema, &t.Function.Parameters); err != nil {
+		return api.Tool{}, fmt.Errorf("tool %q schema is not supported by Ollama: %w", tool.Name(), err)
+	}
+	return t, nil
+}
+
+// ollamaRequest builds a chat request from a provider request
+func ollamaRequest(req Request) *api.ChatRequest {
+	msgs := make([]api.Message, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		msg := api.Message{
+			Role:    string(m.Role),
+			Content: m.Content,
+		}
+		for _, a := range m.Attachments {
+			msg.Images = append(msg.Images, api.ImageData(a.Data))
+		}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, api.ToolCall{
+				Function: api.ToolCallFunction{
+					Name:      call.Name,
+					Arguments: toolInput(call.Input),
+				},
+			})
+		}
+		msgs = append(msgs, msg)
+	}
+
+	options := map[string]any{}
+	if req.Options.MaxTokens > 0 {
+		options["num_predict"] = req.Options.MaxTokens
+	}
+	if req.Options.Temperature != nil {
+		options["temperature"] = *req.Options.Temperature
+	}
+	if req.Options.TopP != nil {
+		options["top_p"] = *req.Options.TopP
+	}
+	if len(req.Options.Stop) > 0 {
+		options["stop"] = req.Options.Stop
+	}
+
+	return &api.ChatRequest{
+		Model:    req.Model,
+		Messages: msgs,
+		Options:  options,
+	}
+}
+
+// ollamaError classifies an Ollama API error
+func ollamaError(err error) error {
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return classify(err, statusErr.StatusCode, statusErr.ErrorMessage)
+	}
+	// Chat reports errors in the stream without their status
+	return classify(err, 0, err.Error())
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,273 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+func init() {
+	Register("openai", "gpt-4", newOpenAI)
+}
+
+// openaiProvider talks to the OpenAI chat completions API
+type openaiProvider struct {
+	client *openai.Client
+}
+
+func newOpenAI(cfg config.AIConfig) (Provider, error) {
+	apiKey, err := config.Resolve(cfg.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("ai.api_key: %w", err)
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("OPENAI_API_KEY")
+	}
+	clientConfig := openai.DefaultConfig(apiKey)
+	clientConfig.HTTPClient = httpClient(cfg)
+	return &openaiProvider{client: openai.NewClientWithConfig(clientConfig)}, nil
+}
+
+func (p *openaiProvider) Chat(ctx context.Context, req Request) (Response, error) {
+	if err := imagesOnly("openai", req); err != nil {
+		return Response{}, err
+	}
+	request := openaiRequest(req)
+	for _, tool := range req.Tools {
+		request.Tools = append(request.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  tool.Schema(),
+			},
+		})
+	}
+
+	resp, err := p.client.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return Response{}, fmt.Errorf("openai chat failed: %w", openaiError(err))
+	}
+	if len(resp.Choices) == 0 {
+		return Response{}, fmt.Errorf("no response from model")
+	}
+
+	msg := resp.Choices[0].Message
+	result := Response{
+		Text: msg.Content,
+		Usage: Usage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+		},
+		StopReason: string(resp.Choices[0].FinishReason),
+	}
+	for _, call := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: json.RawMessage(call.Function.Arguments),
+		})
+	}
+	return result, nil
+}
+
+func (p *openaiProvider) Stream(ctx context.Context, req Request) (<-chan Chunk, error) {
+	if err := imagesOnly("openai", req); err != nil {
+		return nil, err
+	}
+	request := openaiRequest(req)
+	request.Stream = true
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := p.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("openai chat stream failed: %w", openaiError(err))
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+		defer stream.Close()
+
+		// Usage arrives in a final chunk without choices
+		last := Chunk{}
+		for {
+			resp, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				send(ctx, chunks, last)
+				return
+			}
+			if err != nil {
+				send(ctx, chunks, Chunk{Err: fmt.Errorf("openai chat stream failed: %w", openaiError(err))})
+				return
+			}
+			if resp.Usage != nil {
+				last.Usage = &Usage{
+					InputTokens:  resp.Usage.PromptTokens,
+					OutputTokens: resp.Usage.CompletionTokens,
+				}
+			}
+			if len(resp.Choices) == 0 {
+				continue
+			}
+			if reason := resp.Choices[0].FinishReason; reason != "" {
+				last.StopReason = string(reason)
+			}
+			if resp.Choices[0].Delta.Content == "" {
+				continue
+			}
+			if !send(ctx, chunks, Chunk{Text: resp.Choices[0].Delta.Content}) {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
+
+func (p *openaiProvider) ListModels(ctx context.Context) ([]Model, error) {
+	list, err := p.client.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("openai list models failed: %w", openaiError(err))
+	}
+
+	models := make([]Model, 0, len(list.Models))
+	for _, m := range list.Models {
+		in, out := openaiModalities(m.ID)
+		models = append(models, Model{
+			ID:               m.ID,
+			Provider:         "openai",
+			ContextWindow:    ContextWindow(m.ID),
+			InputModalities:  in,
+			OutputModalities: out,
+		})
+	}
+	return models, nil
+}
+
+func (p *openaiProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, Usage, error) {
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input: texts,
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, Usage{}, fmt.Errorf("openai embeddings failed: %w", openaiError(err))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	return vectors, Usage{InputTokens: resp.Usage.PromptTokens}, nil
+}
+
+func (p *openaiProvider) DefaultEmbeddingModel() string {
+	return string(openai.SmallEmbedding3)
+}
+
+// openaiModalities infers a model's modalities from its ID, since the
+// models endpoint does not report them
+func openaiModalities(id string) (in, out []string) {
+	switch {
+	case strings.Contains(id, "embedding"):
+		return []string{ModalityText}, []string{ModalityEmbedding}
+	case strings.HasPrefix(id, "dall-e"):
+		return []string{ModalityText}, []string{ModalityImage}
+	case strings.HasPrefix(id, "whisper"):
+		return []string{ModalityAudio}, []string{ModalityText}
+	case strings.HasPrefix(id, "tts"):
+		return []string{ModalityText}, []string{ModalityAudio}
+	case strings.HasPrefix(id, "gpt-4o"), strings.HasPrefix(id, "gpt-4-turbo"):
+		return []string{ModalityText, ModalityImage}, []string{ModalityText}
+	default:
+		return []string{ModalityText}, []string{ModalityText}
+	}
+}
+
+// openaiRequest builds a chat completion request from a provider request
+func openaiRequest(req Request) openai.ChatCompletionRequest {
+	request := openai.ChatCompletionRequest{
+		Model:     req.Model,
+		Messages:  openaiMessages(req.Messages),
+		MaxTokens: req.Options.MaxTokens,
+		Stop:      req.Options.Stop,
+	}
+	if t := req.Options.Temperature; t != nil {
+		request.Temperature = float32(*t)
+		if *t == 0 {
+			// A zero temperature is omitted from the request, which means 1
+			request.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+	if p := req.Options.TopP; p != nil {
+		request.TopP = float32(*p)
+	}
+	return request
+}
+
+// openaiMessages converts a conversation into OpenAI chat messages
+func openaiMessages(messages []Message) []openai.ChatCompletionMessage {
+	msgs := make([]openai.ChatCompletionMessage, 0, len(messages))
+	for _, m := range messages {
+		msg := openai.ChatCompletionMessage{
+			Role:    string(m.Role),
+			Content: m.Content,
+		}
+		if len(m.Attachments) > 0 {
+			// Content and MultiContent are mutually exclusive
+			msg.Content = ""
+			msg.MultiContent = []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: m.Content},
+			}
+			for _, a := range m.Attachments {
+				msg.MultiContent = append(msg.MultiContent, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: a.dataURL()},
+				})
+			}
+		}
+		for _, call := range m.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Name,
+					Arguments: string(call.Input),
+				},
+			})
+		}
+		if m.ToolResult != nil {
+			msg.ToolCallID = m.ToolResult.CallID
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// openaiError classifies an OpenAI API error
+func openaiError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		code, _ := apiErr.Code.(string)
+		return classify(err, apiErr.HTTPStatusCode, code+" "+apiErr.Message)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return classify(err, reqErr.HTTPStatusCode, string(reqErr.Body))
+	}
+	return err
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,58 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import "github.com/fast-ish/${{values.name}}/internal/config"
+
+// DefaultMaxTokens is the output limit used when none is configured and the
+// provider requires one
+const DefaultMaxTokens = 4096
+
+// GenerationOptions controls how a model generates a response. Zero values
+// leave the provider's defaults in place; Temperature and TopP are pointers
+// so that an explicit 0 can be told apart from unset.
+type GenerationOptions struct {
+	MaxTokens   int
+	Temperature *float64
+	TopP        *float64
+	Stop        []string
+	System      string
+}
+
+// OptionsFromConfig returns the generation options set in configuration
+func OptionsFromConfig(cfg config.AIConfig) GenerationOptions {
+	return GenerationOptions{
+		MaxTokens:   cfg.MaxTokens,
+		Temperature: cfg.Temperature,
+		TopP:        cfg.TopP,
+		Stop:        cfg.Stop,
+		System:      cfg.System,
+	}
+}
+
+// Merge returns o with every option set in other applied on top
+func (o GenerationOptions) Merge(other GenerationOptions) GenerationOptions {
+	if other.MaxTokens > 0 {
+		o.MaxTokens = other.MaxTokens
+	}
+	if other.Temperature != nil {
+		o.Temperature = other.Temperature
+	}
+	if other.TopP != nil {
+		o.TopP = other.TopP
+	}
+	if len(other.Stop) > 0 {
+		o.Stop = other.Stop
+	}
+	if other.System != "" {
+		o.System = other.System
+	}
+	return o
+}
+
+// Float returns a pointer to v, for setting Temperature and TopP
+func Float(v float64) *float64 {
+	return &v
+}
+{%- else %}
+package ai
+{%- endif %}
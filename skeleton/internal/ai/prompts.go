@@ -0,0 +1,250 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// Prompt sources, from lowest to highest precedence
+const (
+	PromptSourceBuiltin = "builtin"
+	PromptSourceConfig  = "config"
+)
+
+// builtinPrompts are the prompts the AI commands use. Users can override
+// any of them by name. The main input is always .Text.
+var builtinPrompts = []struct {
+	name, description, text string
+}{
+	{% raw %}{"analyze", "Analyze text and provide insights",
+		"Analyze the following text and provide insights:\n\n{{.Text}}"},
+	{"analyze-attachments", "Analyze attached images or documents, with optional text",
+		"Analyze the attached files{{if .Text}} together with the following text{{end}} and provide insights.{{if .Text}}\n\n{{.Text}}{{end}}"},
+	{"analyze-combine", "Combine the analyses of the parts of a long text",
+		"The following are analyses of consecutive parts of one text. Combine them into a single analysis of the whole text:\n\n{{.Text}}"},
+	{"analyze-json", "Restate an analysis as JSON",
+		"Express the following analysis as JSON:\n\n{{.Text}}"},
+	{"ask", "Answer a question from numbered document excerpts",
+		"Answer the question using only the numbered excerpts below, citing the excerpts you use as [1], [2] and so on. If they do not contain the answer, say so.\n\nQuestion: {{.Question}}\n\nExcerpts:\n\n{{.Text}}"},
+	{"compact", "Summarize the earlier turns of a long conversation",
+		"Summarize the following conversation so that it can be continued from the summary alone. Keep the facts, decisions, names, numbers, code and open questions it established.\n\n{{.Text}}"},
+	{"summarize", "Summarize text concisely",
+		"Summarize the following text concisely:\n\n{{.Text}}"},
+	{"summarize-combine", "Combine the summaries of the parts of a long text",
+		"The following are summaries of consecutive parts of one text. Combine them into a single concise summary:\n\n{{.Text}}"},
+	{"judge", "Grade a model's output against criteria",
+		"You are grading the output of an AI model. Decide whether the output meets the criteria, judging only by the criteria.\n\nCriteria: {{.Criteria}}\n\nInput:\n\n{{.Input}}\n\nOutput:\n\n{{.Text}}"},
+	{"review", "Review a code change",
+		"Review the following code change. List bugs, risky changes and missing tests, most important first, citing the lines concerned. Say so if there are none.\n\n{{.Text}}"},
+	{"shell", "Suggest a shell command for a task",
+		"Suggest a single {{.Shell}} command for {{.OS}} that does the following, explain what it does, and rate its risk: low if it only reads, medium if it changes files or settings that can easily be restored, high if it deletes data or cannot be undone.\n\n{{.Text}}"},
+	{"triage", "Triage an issue or incident report",
+		"Triage the following report. Give its likely severity (critical, high, medium or low), the affected component, the probable cause and the next steps.\n\n{{.Text}}"},{% endraw %}
+}
+
+// descriptionPrefix and descriptionSuffix delimit a template comment on
+// the first line of a prompt file, which is used as its description
+const descriptionPrefix, descriptionSuffix = {% raw %}"{{/*", "*/}}"{% endraw %}
+
+// Prompt is a named text/template that renders a prompt from variables
+type Prompt struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Source is PromptSourceBuiltin, PromptSourceConfig or the file path
+	Source string `json:"source" yaml:"source"`
+	Text   string `json:"text" yaml:"text"`
+
+	tmpl *template.Template
+}
+
+// NewPrompt parses a prompt template. Referring to a variable that is not
+// given is an error when the prompt is rendered.
+func NewPrompt(name, description, source, text string) (*Prompt, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt %q from %s: %w", name, source, err)
+	}
+	return &Prompt{Name: name, Description: description, Source: source, Text: text, tmpl: tmpl}, nil
+}
+
+// Render executes the template with vars
+func (p *Prompt) Render(vars map[string]string) (string, error) {
+	var b strings.Builder
+	if err := p.tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt %q: %w", p.Name, err)
+	}
+	return b.String(), nil
+}
+
+// Variables returns the names of the variables the template refers to,
+// sorted
+func (p *Prompt) Variables() []string {
+	seen := map[string]bool{}
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			seen[n.Ident[0]] = true
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	walk(p.tmpl.Tree.Root)
+
+	vars := make([]string, 0, len(seen))
+	for name := range seen {
+		vars = append(vars, name)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// PromptLibrary holds the prompts available by name
+type PromptLibrary struct {
+	prompts map[string]*Prompt
+}
+
+// BuiltinPrompts returns a library with only the built-in prompts
+func BuiltinPrompts() *PromptLibrary {
+	lib := &PromptLibrary{prompts: map[string]*Prompt{}}
+	for _, b := range builtinPrompts {
+		p, err := NewPrompt(b.name, b.description, PromptSourceBuiltin, b.text)
+		if err != nil {
+			// Built-in prompts are fixed at compile time
+			panic(err)
+		}
+		lib.prompts[p.Name] = p
+	}
+	return lib
+}
+
+// LoadPrompts returns the built-in prompts, overridden and extended by
+// *.tmpl files in the prompts directory and then by ai.prompts in config.
+// A file's name without the extension is the prompt's name.
+func LoadPrompts(cfg config.AIConfig) (*PromptLibrary, error) {
+	lib := BuiltinPrompts()
+
+	dir, err := PromptsDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt: %w", err)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		p, err := NewPrompt(name, fileDescription(string(data)), path, string(data))
+		if err != nil {
+			return nil, err
+		}
+		lib.prompts[name] = p
+	}
+
+	for _, pc := range cfg.Prompts {
+		if pc.Name == "" {
+			return nil, errors.New("invalid prompt in config: name is required")
+		}
+		p, err := NewPrompt(pc.Name, pc.Description, PromptSourceConfig, pc.Template)
+		if err != nil {
+			return nil, err
+		}
+		lib.prompts[pc.Name] = p
+	}
+
+	return lib, nil
+}
+
+// PromptsDir returns the directory user prompt files are read from
+func PromptsDir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "prompts"), nil
+}
+
+// Get returns the named prompt
+func (l *PromptLibrary) Get(name string) (*Prompt, error) {
+	p, ok := l.prompts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt %q (run `${{values.name}} ai prompt list` to see the available prompts)", name)
+	}
+	return p, nil
+}
+
+// List returns every prompt, sorted by name
+func (l *PromptLibrary) List() []*Prompt {
+	prompts := make([]*Prompt, 0, len(l.prompts))
+	for _, p := range l.prompts {
+		prompts = append(prompts, p)
+	}
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts
+}
+
+// Render renders the named prompt with vars
+func (l *PromptLibrary) Render(name string, vars map[string]string) (string, error) {
+	p, err := l.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return p.Render(vars)
+}
+
+// fileDescription returns the text of a template comment on the first line
+// of a prompt file, if there is one
+func fileDescription(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, descriptionPrefix) || !strings.HasSuffix(line, descriptionSuffix) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, descriptionPrefix), descriptionSuffix))
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,124 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// Provider is implemented by each AI backend. Providers register themselves
+// with Register from an init function so a single binary can carry several
+// of them and pick one at runtime.
+type Provider interface {
+	// Chat returns the complete reply to a request
+	Chat(ctx context.Context, req Request) (Response, error)
+	// Stream returns a channel that yields the reply incrementally. The
+	// channel is closed when the reply is complete or ctx is cancelled.
+	Stream(ctx context.Context, req Request) (<-chan Chunk, error)
+	// ListModels returns the models the provider offers
+	ListModels(ctx context.Context) ([]Model, error)
+}
+
+// Embedder is implemented by providers that offer text embeddings
+type Embedder interface {
+	// Embed returns one vector per text, in the same order
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, Usage, error)
+	// DefaultEmbeddingModel is used when ai.embedding_model is not set
+	DefaultEmbeddingModel() string
+}
+
+// Request is a provider-agnostic completion request
+type Request struct {
+	Model    string
+	Messages []Message
+	Options  GenerationOptions
+	// Tools the model may call. Streaming ignores them.
+	Tools []Tool
+}
+
+// Response is a provider's complete reply
+type Response struct {
+	Text       string
+	ToolCalls  []ToolCall
+	Usage      Usage
+	StopReason string
+	// Latency is measured by the client, not the provider
+	Latency time.Duration
+}
+
+// Model describes a model offered by a provider
+type Model struct {
+	ID               string   `json:"id" yaml:"id"`
+	Name             string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Provider         string   `json:"provider" yaml:"provider"`
+	ContextWindow    int      `json:"context_window,omitempty" yaml:"context_window,omitempty"`
+	InputModalities  []string `json:"input_modalities,omitempty" yaml:"input_modalities,omitempty"`
+	OutputModalities []string `json:"output_modalities,omitempty" yaml:"output_modalities,omitempty"`
+}
+
+// Factory creates a provider from configuration
+type Factory func(cfg config.AIConfig) (Provider, error)
+
+type registration struct {
+	factory      Factory
+	defaultModel string
+}
+
+var registry = map[string]registration{}
+
+// Register makes a provider selectable by name through ai.provider or
+// --provider. defaultModel is used when no model is configured.
+func Register(name, defaultModel string, factory Factory) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("ai: provider %q registered twice", name))
+	}
+	registry[name] = registration{factory: factory, defaultModel: defaultModel}
+}
+
+// Providers returns the names of all registered providers, sorted
+func Providers() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsProvider reports whether name is a registered provider
+func IsProvider(name string) bool {
+	_, ok := registry[name]
+	return ok
+}
+
+// NewProvider creates the provider selected by cfg.Provider
+func NewProvider(cfg config.AIConfig) (Provider, error) {
+	reg, ok := registry[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider %q (available: %s)", cfg.Provider, strings.Join(Providers(), ", "))
+	}
+	return reg.factory(cfg)
+}
+
+// DefaultModel returns the model a provider uses when none is configured
+func DefaultModel(provider string) string {
+	return registry[provider].defaultModel
+}
+
+// send delivers a chunk to a stream, giving up if the context is cancelled
+func send(ctx context.Context, chunks chan<- Chunk, chunk Chunk) bool {
+	select {
+	case chunks <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+{%- else %}
+package ai
+{%- endif %}
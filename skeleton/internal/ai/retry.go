@@ -0,0 +1,170 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+const (
+	// DefaultMaxAttempts bounds the attempts of one provider call when
+	// ai.max_attempts is not set
+	DefaultMaxAttempts = 3
+
+	// retryBaseDelay and retryMaxDelay bound the exponential backoff
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+
+	// maxRetryAfter caps how long a Retry-After header can make us wait
+	maxRetryAfter = 2 * time.Minute
+)
+
+// retryTransport retries provider HTTP calls that failed transiently, with
+// exponential backoff and full jitter, honouring Retry-After. An optional
+// token bucket spaces out all requests, including retries.
+type retryTransport struct {
+	base        http.RoundTripper
+	maxAttempts int
+	limiter     *rate.Limiter
+}
+
+// httpClient returns the HTTP client providers send requests with. Provider
+// SDKs that retry on their own must have that disabled so ai.max_attempts
+// is the only bound.
+func httpClient(cfg config.AIConfig) *http.Client {
+	t := &retryTransport{
+		base:        http.DefaultTransport,
+		maxAttempts: cfg.MaxAttempts,
+	}
+	if t.maxAttempts < 1 {
+		t.maxAttempts = DefaultMaxAttempts
+	}
+	if cfg.RequestsPerMinute > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(float64(cfg.RequestsPerMinute)/60), 1)
+	}
+	return &http.Client{Transport: t}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// Buffer the body so that it can be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(ctx)
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+
+	for attempt := 1; ; attempt++ {
+		if t.limiter != nil {
+			if err := t.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		try := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			try = req.Clone(ctx)
+			try.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(try)
+		if attempt >= t.maxAttempts || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay := backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp.Header); ok {
+				delay = after
+			}
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether a failed attempt may succeed if repeated:
+// network errors, throttling and server errors
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic: overloaded
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the next attempt: a random duration up
+// to an exponentially growing, capped ceiling
+func backoff(attempt int) time.Duration {
+	ceiling := retryMaxDelay
+	if shift := attempt - 1; shift < 16 {
+		ceiling = min(retryBaseDelay<<shift, retryMaxDelay)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// retryAfter parses a Retry-After header, given in seconds or as an HTTP
+// date
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at)
+	} else {
+		return 0, false
+	}
+	return max(0, min(delay, maxRetryAfter)), true
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,252 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// fakeServer is a fake provider that answers with statuses in turn,
+// repeating the last, and fails the test if a request arrives without its
+// body
+type fakeServer struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newFakeServer(t *testing.T, retryAfter string, statuses ...int) *fakeServer {
+	t.Helper()
+	p := &fakeServer{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(p.requests.Add(1))
+		body, err := io.ReadAll(r.Body)
+		if err != nil || string(body) != `{"prompt":"hi"}` {
+			t.Errorf("request %d: body = %q, %v", n, body, err)
+		}
+		status := statuses[min(n, len(statuses))-1]
+		if status != http.StatusOK && retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// post sends a request with a body to the provider through client
+func (p *fakeServer) post(ctx context.Context, client *http.Client) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, strings.NewReader(`{"prompt":"hi"}`))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryTransportRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		status   int
+	}{
+		{"success", []int{200}, 1, 200},
+		{"throttled", []int{429, 200}, 2, 200},
+		{"server errors", []int{500, 502, 200}, 3, 200},
+		{"unavailable", []int{503, 200}, 2, 200},
+		{"gateway timeout", []int{504, 200}, 2, 200},
+		{"overloaded", []int{529, 200}, 2, 200},
+		{"max attempts", []int{503}, 3, 503},
+		{"bad request", []int{400, 200}, 1, 400},
+		{"unauthorized", []int{401, 200}, 1, 401},
+		{"not found", []int{404, 200}, 1, 404},
+		{"unprocessable", []int{422, 200}, 1, 422},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeServer(t, "0", tt.statuses...)
+			resp, err := p.post(context.Background(), httpClient(config.AIConfig{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := int(p.requests.Load()); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportMaxAttempts(t *testing.T) {
+	for _, maxAttempts := range []int{1, 2, 5} {
+		p := newFakeServer(t, "0", 429)
+		resp, err := p.post(context.Background(), httpClient(config.AIConfig{MaxAttempts: maxAttempts}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 429 {
+			t.Errorf("max %d: status = %d, want 429", maxAttempts, resp.StatusCode)
+		}
+		if got := int(p.requests.Load()); got != maxAttempts {
+			t.Errorf("max %d: attempts = %d", maxAttempts, got)
+		}
+	}
+}
+
+func TestRetryTransportBackoff(t *testing.T) {
+	// Without Retry-After, the first retry waits less than the base delay
+	p := newFakeServer(t, "", 503, 200)
+	start := time.Now()
+	resp, err := p.post(context.Background(), httpClient(config.AIConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || p.requests.Load() != 2 {
+		t.Errorf("status = %d after %d attempts, want 200 after 2", resp.StatusCode, p.requests.Load())
+	}
+	if limit := retryBaseDelay + 250*time.Millisecond; time.Since(start) >= limit {
+		t.Errorf("took %s, want under %s", time.Since(start), limit)
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for Retry-After")
+	}
+	headers := []func() string{
+		func() string { return "1" },
+		func() string { return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat) },
+	}
+	for _, next := range headers {
+		header := next()
+		p := newFakeServer(t, header, 429, 200)
+		start := time.Now()
+		resp, err := p.post(context.Background(), httpClient(config.AIConfig{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Errorf("Retry-After %q: status = %d, want 200", header, resp.StatusCode)
+		}
+		// HTTP dates have a resolution of a second
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+			t.Errorf("Retry-After %q: retried after %s", header, elapsed)
+		}
+	}
+}
+
+func TestRetryTransportCancel(t *testing.T) {
+	p := newFakeServer(t, "60", 503)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.post(ctx, httpClient(config.AIConfig{}))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %s, want on cancellation", elapsed)
+	}
+	if got := p.requests.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+func TestRetryTransportRateLimit(t *testing.T) {
+	// 1200 a minute is one every 50ms, retries included
+	client := httpClient(config.AIConfig{RequestsPerMinute: 1200})
+	p := newFakeServer(t, "0", 429, 200)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := p.post(context.Background(), client); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := p.requests.Load(); got != 4 {
+		t.Fatalf("requests = %d, want 4", got)
+	}
+	// Three gaps of 50ms, less 10ms for timer slack
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 requests took %s, want at least 140ms", elapsed)
+	}
+}
+
+func TestHTTPClientDefaults(t *testing.T) {
+	transport := httpClient(config.AIConfig{}).Transport.(*retryTransport)
+	if transport.maxAttempts != DefaultMaxAttempts {
+		t.Errorf("maxAttempts = %d, want %d", transport.maxAttempts, DefaultMaxAttempts)
+	}
+	if transport.limiter != nil {
+		t.Error("limiter is set without ai.requests_per_minute")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 70; attempt++ {
+		ceiling := retryMaxDelay
+		if attempt <= 16 {
+			ceiling = min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+		}
+		var longest time.Duration
+		for i := 0; i < 200; i++ {
+			delay := backoff(attempt)
+			if delay < 0 || delay >= ceiling {
+				t.Fatalf("attempt %d: delay %s outside [0, %s)", attempt, delay, ceiling)
+			}
+			longest = max(longest, delay)
+		}
+		// Full jitter spreads the delays over the whole range
+		if longest < ceiling/2 {
+			t.Errorf("attempt %d: longest of 200 delays is %s, want near %s", attempt, longest, ceiling)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+		ok    bool
+	}{
+		{"", 0, 0, false},
+		{"soon", 0, 0, false},
+		{"0", 0, 0, true},
+		{"7", 7 * time.Second, 7 * time.Second, true},
+		{"-5", 0, 0, true},
+		{"3600", maxRetryAfter, maxRetryAfter, true},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second, true},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0, true},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), maxRetryAfter, maxRetryAfter, true},
+		{time.Now().Add(30 * time.Second).UTC().Format(time.RFC850), 28 * time.Second, 30 * time.Second, true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.value != "" {
+			header.Set("Retry-After", tt.value)
+		}
+		delay, ok := retryAfter(header)
+		if ok != tt.ok || delay < tt.min || delay > tt.max {
+			t.Errorf("retryAfter(%q) = %s, %t; want [%s, %s], %t", tt.value, delay, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+{%- else %}
+package ai
+{%- endif %}
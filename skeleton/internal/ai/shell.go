@@ -0,0 +1,96 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime"
+)
+
+// Risk ratings of a suggested command
+const (
+	RiskLow    = "low"    // only reads
+	RiskMedium = "medium" // changes state that is easily restored
+	RiskHigh   = "high"   // deletes data or cannot be undone
+)
+
+// commandSchema is the reply SuggestCommand asks for
+var commandSchema = mustCompileSchema("command.json", `{
+  "type": "object",
+  "properties": {
+    "command": {"type": "string", "minLength": 1},
+    "explanation": {"type": "string"},
+    "risk": {"enum": ["low", "medium", "high"]}
+  },
+  "required": ["command", "explanation", "risk"]
+}`)
+
+// destructivePatterns match commands that delete data or are hard to undo,
+// whatever risk the model gave them
+var destructivePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(^|[\s;&|(])(sudo\s+)?(rm|rmdir|unlink|srm)(\s|$)`),
+	regexp.MustCompile(`\b(shred|mkfs(\.\w+)?|fdisk|parted|wipefs|truncate)\b`),
+	regexp.MustCompile(`\bdd\b.*\bof=`),
+	regexp.MustCompile(`(^|[^<>])>\|?\s*[^\s>&]`), // truncates a file
+	regexp.MustCompile(`\bsed\s+(-\S+\s+)*-i`),
+	regexp.MustCompile(`\b(chmod|chown)\s+(-\S+\s+)*-\S*R`),
+	regexp.MustCompile(`\bfind\b.*\s-(delete|exec\s+rm)\b`),
+	regexp.MustCompile(`\bgit\s+(push\s.*(--force|-f\b)|reset\s+--hard|clean\s+-\S*f|branch\s+-D)`),
+	regexp.MustCompile(`\bkubectl\s+(delete|drain)\b`),
+	regexp.MustCompile(`\b(terraform|tofu)\s+destroy\b`),
+	regexp.MustCompile(`\baws\s+\S+\s+(rm|rb|delete-\S+|terminate-instances)\b`),
+	regexp.MustCompile(`\bdocker\s+(system|volume|image|container)\s+prune\b`),
+	regexp.MustCompile(`(?i)\b(drop|truncate)\s+(table|database|schema)\b`),
+	regexp.MustCompile(`\b(shutdown|reboot|halt|poweroff)\b`),
+	regexp.MustCompile(`\bkill(all)?\s+(-\S+\s+)*-(9|KILL)\b`),
+	regexp.MustCompile(`:\(\)\s*\{`),
+}
+
+// safeRedirects are redirections that cannot overwrite a file, removed
+// before matching destructivePatterns
+var safeRedirects = regexp.MustCompile(`\d*(>>?|&>)\s*/dev/(null|stdout|stderr|tty)\b|\d*>&\d+|\d*>>\s*\S+`)
+
+// CommandSuggestion is a shell command proposed for a task
+type CommandSuggestion struct {
+	Command     string `json:"command" yaml:"command"`
+	Explanation string `json:"explanation" yaml:"explanation"`
+	Risk        string `json:"risk" yaml:"risk"`
+}
+
+// Destructive reports whether the command matches a pattern of commands
+// that delete data or are hard to undo
+func (s CommandSuggestion) Destructive() bool {
+	command := safeRedirects.ReplaceAllString(s.Command, "")
+	for _, re := range destructivePatterns {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestCommand asks for a command for shell that does task. A destructive
+// command is rated high risk whatever the model said.
+func (c *Client) SuggestCommand(ctx context.Context, task, shell string) (*CommandSuggestion, error) {
+	prompt, err := c.prompts.Render("shell", map[string]string{"Text": task, "Shell": shell, "OS": runtime.GOOS})
+	if err != nil {
+		return nil, err
+	}
+	value, err := c.Structured(ctx, prompt, commandSchema, GenerationOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var s CommandSuggestion
+	if err := decode(value, &s); err != nil {
+		return nil, fmt.Errorf("invalid suggestion: %w", err)
+	}
+	if s.Destructive() {
+		s.Risk = RiskHigh
+	}
+	return &s, nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,129 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// maxSchemaAttempts bounds how often a reply that does not match the schema
+// is sent back to the model for correction
+const maxSchemaAttempts = 3
+
+// Schema is a compiled JSON Schema that structured replies must match
+type Schema struct {
+	source   string
+	compiled *jsonschema.Schema
+}
+
+// LoadSchema reads and compiles a JSON Schema file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	// The URL resolves relative $refs to files next to the schema
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := jsonschema.CompileString("file://"+filepath.ToSlash(abs), string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	return &Schema{source: string(data), compiled: compiled}, nil
+}
+
+// mustCompileSchema compiles a built-in schema. The absolute URL keeps the
+// compiler from resolving name against the working directory.
+func mustCompileSchema(name, source string) *Schema {
+	return &Schema{source: source, compiled: jsonschema.MustCompileString("mem:///"+name, source)}
+}
+
+// Validate parses data as JSON and checks it against the schema
+func (s *Schema) Validate(data []byte) (any, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+	if err := s.compiled.Validate(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Structured sends prompt asking for a JSON reply that matches schema. A
+// reply that does not match is sent back with the validation errors, up to
+// a few times. It returns the parsed value.
+func (c *Client) Structured(ctx context.Context, prompt string, schema *Schema, opts GenerationOptions) (any, error) {
+	return c.structured(ctx, Message{Role: RoleUser, Content: prompt}, schema, opts)
+}
+
+// structured is Structured for a prompt message that may carry attachments
+func (c *Client) structured(ctx context.Context, prompt Message, schema *Schema, opts GenerationOptions) (any, error) {
+	prompt.Content = fmt.Sprintf("%s\n\nRespond with only a JSON value, without commentary or code fences, that matches this JSON Schema:\n\n%s",
+		prompt.Content, schema.source)
+	messages := []Message{prompt}
+
+	// Only replies that match are cached, so a mismatch is not replayed
+	check := func(resp Response) error {
+		_, err := schema.Validate([]byte(StripCodeFence(resp.Text)))
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < maxSchemaAttempts; attempt++ {
+		resp, err := c.chatChecked(ctx, c.request(messages, c.opts.Merge(opts)), check)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := schema.Validate([]byte(StripCodeFence(resp.Text)))
+		if err == nil {
+			return value, nil
+		}
+		lastErr = err
+
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: resp.Text},
+			Message{Role: RoleUser, Content: fmt.Sprintf("That reply is invalid:\n\n%v\n\nRespond again with only the corrected JSON.", err)},
+		)
+	}
+
+	return nil, fmt.Errorf("reply did not match the schema after %d attempts: %w", maxSchemaAttempts, lastErr)
+}
+
+// decode converts a value returned by Structured into out, a pointer to a
+// struct matching the schema
+func decode(value any, out any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// StripCodeFence removes the Markdown code fence models often put around
+// JSON despite being asked not to
+func StripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	// Drop the info string, e.g. "json"
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,97 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// charsPerToken is a rough estimate; real tokenizers vary by model
+	charsPerToken = 4
+	// defaultContextWindow is assumed for models missing from the table
+	defaultContextWindow = 8192
+
+	// messageTokens is the overhead of a message's role and delimiters
+	messageTokens = 4
+	// imageTokens is about what a provider charges for a typical image
+	imageTokens = 1600
+	// documentBytesPerToken is a rough average for text PDFs
+	documentBytesPerToken = 50
+)
+
+// tokenRatios maps model ID fragments to the average number of characters
+// per token of their tokenizers on English text and code. The first match
+// wins, so more specific fragments come first.
+var tokenRatios = []struct {
+	fragment string
+	chars    float64
+}{
+	{"claude", 3.5},
+	{"gpt-4o", 4.2},
+	{"o1", 4.2},
+	{"gpt", 4},
+	{"llama3", 4},
+	{"llama", 3.5},
+	{"mixtral", 3.5},
+	{"mistral", 3.5},
+	{"titan", 4},
+	{"command", 4},
+}
+
+// CountTokens estimates the number of tokens text takes for model. No
+// provider offers a stable local tokenizer, so this uses the model family's
+// average token length; expect it to be off by ten percent or so.
+func CountTokens(model, text string) int {
+	chars := float64(charsPerToken)
+	id := strings.ToLower(model)
+	for _, r := range tokenRatios {
+		if strings.Contains(id, r.fragment) {
+			chars = r.chars
+			break
+		}
+	}
+	return int(math.Ceil(float64(len(text)) / chars))
+}
+
+// CountMessageTokens estimates the number of tokens messages take for
+// model, including attachments and tool calls
+func CountMessageTokens(model string, messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += messageTokens + CountTokens(model, m.Content)
+		for _, a := range m.Attachments {
+			if a.IsImage() {
+				total += imageTokens
+			} else {
+				total += len(a.Data) / documentBytesPerToken
+			}
+		}
+		for _, call := range m.ToolCalls {
+			total += CountTokens(model, call.Name) + CountTokens(model, string(call.Input))
+		}
+	}
+	return total
+}
+
+// CountTokens estimates the number of tokens messages take for the
+// client's model
+func (c *Client) CountTokens(messages []Message) int {
+	return CountMessageTokens(c.cfg.Model, messages)
+}
+
+// ContextWindow returns the context window of the client's model in
+// tokens: ai.context_window if set, else the model's known window, else a
+// conservative default
+func (c *Client) ContextWindow() int {
+	if c.cfg.ContextWindow > 0 {
+		return c.cfg.ContextWindow
+	}
+	if window := ContextWindow(c.cfg.Model); window > 0 {
+		return window
+	}
+	return defaultContextWindow
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,127 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Tool is a function the model can ask to call
+type Tool interface {
+	// Name identifies the tool to the model
+	Name() string
+	// Description tells the model what the tool does and when to use it
+	Description() string
+	// Schema is the JSON Schema of the tool's input object
+	Schema() map[string]any
+	// ReadOnly reports whether the tool only reads state. Tools that change
+	// state must be approved before they run.
+	ReadOnly() bool
+	// Call runs the tool with input matching Schema and returns its result
+	Call(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// ToolProvider is implemented by clients that expose tools to the model
+type ToolProvider interface {
+	Tools() []Tool
+}
+
+// ToolCall is a request from the model to call a tool
+type ToolCall struct {
+	ID    string          `json:"id" yaml:"id"`
+	Name  string          `json:"name" yaml:"name"`
+	Input json.RawMessage `json:"input" yaml:"input"`
+}
+
+// ToolResult identifies the call a tool message answers
+type ToolResult struct {
+	CallID  string `json:"call_id" yaml:"call_id"`
+	Name    string `json:"name" yaml:"name"`
+	IsError bool   `json:"is_error,omitempty" yaml:"is_error,omitempty"`
+}
+
+// funcTool is a Tool backed by a function
+type funcTool struct {
+	name        string
+	description string
+	schema      map[string]any
+	readOnly    bool
+	fn          func(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// NewReadOnlyTool creates a tool that only reads state
+func NewReadOnlyTool(name, description string, schema map[string]any, fn func(ctx context.Context, input json.RawMessage) (string, error)) Tool {
+	return &funcTool{name: name, description: description, schema: schema, readOnly: true, fn: fn}
+}
+
+// NewMutatingTool creates a tool that changes state. Agents ask for approval
+// before calling it.
+func NewMutatingTool(name, description string, schema map[string]any, fn func(ctx context.Context, input json.RawMessage) (string, error)) Tool {
+	return &funcTool{name: name, description: description, schema: schema, fn: fn}
+}
+
+func (t *funcTool) Name() string           { return t.name }
+func (t *funcTool) Description() string    { return t.description }
+func (t *funcTool) Schema() map[string]any { return t.schema }
+func (t *funcTool) ReadOnly() bool         { return t.readOnly }
+
+func (t *funcTool) Call(ctx context.Context, input json.RawMessage) (string, error) {
+	return t.fn(ctx, input)
+}
+
+// ToolRegistry holds the tools offered to the model
+type ToolRegistry struct {
+	tools map[string]Tool
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]Tool)}
+}
+
+// Register adds tools to the registry. Tool names must be unique.
+func (r *ToolRegistry) Register(tools ...Tool) error {
+	for _, tool := range tools {
+		if _, dup := r.tools[tool.Name()]; dup {
+			return fmt.Errorf("tool %q registered twice", tool.Name())
+		}
+		r.tools[tool.Name()] = tool
+	}
+	return nil
+}
+
+// Get returns the named tool
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// List returns the registered tools sorted by name
+func (r *ToolRegistry) List() []Tool {
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name() < tools[j].Name() })
+	return tools
+}
+
+// Len returns the number of registered tools
+func (r *ToolRegistry) Len() int {
+	return len(r.tools)
+}
+
+// toolInput decodes a call's JSON input for SDKs that take a Go value.
+// Missing or invalid input becomes an empty object.
+func toolInput(raw json.RawMessage) map[string]any {
+	input := map[string]any{}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &input)
+	}
+	return input
+}
+{%- else %}
+package ai
+{%- endif %}
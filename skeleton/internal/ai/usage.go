@@ -0,0 +1,29 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import "github.com/fast-ish/${{values.name}}/internal/config"
+
+// Usage counts the tokens consumed by one or more requests
+type Usage struct {
+	InputTokens  int `json:"input_tokens" yaml:"input_tokens"`
+	OutputTokens int `json:"output_tokens" yaml:"output_tokens"`
+}
+
+// Add accumulates another usage record into u
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+}
+
+// Total returns the combined input and output token count
+func (u Usage) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Cost estimates the cost of u in USD at the given per-million-token prices
+func (u Usage) Cost(price config.ModelPrice) float64 {
+	return (float64(u.InputTokens)*price.Input + float64(u.OutputTokens)*price.Output) / 1e6
+}
+{%- else %}
+package ai
+{%- endif %}
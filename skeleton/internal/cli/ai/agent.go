@@ -0,0 +1,113 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent [task]",
+	Short: "Let the AI use tools to complete a task",
+	Long: `Let the AI use tools to complete a task.
+
+The model can read files in the working directory and call the read-only
+tools exposed by configured integrations. Tools that change state ask for
+confirmation first, and are never run with --dry-run.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxSteps, _ := cmd.Flags().GetInt("max-steps")
+		force, _ := cmd.Flags().GetBool("force")
+		return runAgent(cmd.Context(), context.GetGlobal(), strings.Join(args, " "), maxSteps, force)
+	},
+}
+
+func init() {
+	agentCmd.Flags().Int("max-steps", ai.DefaultMaxSteps, "maximum number of model turns")
+	agentCmd.Flags().BoolP("force", "f", false, "run tools that change state without confirmation")
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var agentCmd = &cli.Command{
+	Name:      "agent",
+	Usage:     "Let the AI use tools to complete a task (state changes are confirmed first)",
+	ArgsUsage: "[task]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "max-steps",
+			Value: ai.DefaultMaxSteps,
+			Usage: "maximum number of model turns",
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "run tools that change state without confirmation",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return cli.ShowSubcommandHelp(c)
+		}
+		task := strings.Join(c.Args().Slice(), " ")
+		return runAgent(c.Context, context.GetGlobal(), task, c.Int("max-steps"), c.Bool("force"))
+	},
+}
+{%- endif %}
+
+// runAgent lets the model work on task with the available tools and prints
+// its final answer
+func runAgent(stdctx stdcontext.Context, ctx *context.Context, task string, maxSteps int, force bool) error {
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	tools, err := ctx.Tools()
+	if err != nil {
+		return err
+	}
+	if err := tools.Register(fileTools()...); err != nil {
+		return err
+	}
+
+	agent := ai.NewAgent(client, tools, func(call ai.ToolCall, tool ai.Tool) error {
+		if ctx.DryRun {
+			ctx.Output.DryRun("Would call %s with %s", call.Name, call.Input)
+			return errors.New("dry run, the call was not made")
+		}
+		if !force && !ctx.Confirm(fmt.Sprintf("Allow %s with %s?", call.Name, call.Input), false) {
+			return errors.New("the user declined the call")
+		}
+		return nil
+	})
+	agent.MaxSteps = maxSteps
+	agent.OnToolCall = func(call ai.ToolCall) {
+		ctx.Output.Info(fmt.Sprintf("→ %s %s", call.Name, call.Input))
+	}
+
+	messages, err := agent.Run(stdctx, []ai.Message{
+		{Role: ai.RoleUser, Content: task},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Info(messages[len(messages)-1].Content)
+	return nil
+}
+{%- else %}
+package ai
+{%- endif %}
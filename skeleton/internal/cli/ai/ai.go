@@ -3,13 +3,25 @@
 package ai
 
 import (
+	stdcontext "context"
+	"fmt"
+	"sort"
+	"strings"
+
 {%- if values.cliFramework == "cobra" %}
 	"github.com/spf13/cobra"
 {%- elif values.cliFramework == "urfave" %}
 	"github.com/urfave/cli/v2"
 {%- endif %}
 
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+{%- if "chat" in values.aiFeatures or "generate" in values.aiFeatures %}
+	"github.com/fast-ish/${{values.name}}/internal/config"
+{%- endif %}
 	"github.com/fast-ish/${{values.name}}/internal/context"
+{%- if "chat" in values.aiFeatures %}
+	"github.com/fast-ish/${{values.name}}/internal/session"
+{%- endif %}
 )
 
 {%- if values.cliFramework == "cobra" %}
@@ -18,23 +30,73 @@ import (
 var Cmd = &cobra.Command{
 	Use:   "ai",
 	Short: "AI-powered operations",
-	Long:  "AI-powered operations using {{values.aiProvider}}",
+	Long: `AI-powered operations.
+
+The provider defaults to ${{values.aiProvider}}. Select another with --provider or ai.provider in config.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Cobra only runs the closest persistent hook, so chain to the root's
+		if err := cmd.Root().PersistentPreRunE(cmd, args); err != nil {
+			return err
+		}
+
+		provider, _ := cmd.Flags().GetString("provider")
+		model, _ := cmd.Flags().GetString("model")
+		if err := selectProvider(context.GetGlobal(), provider, model); err != nil {
+			return err
+		}
+
+		noCache, _ := cmd.Flags().GetBool("no-cache")
+		cacheOnly, _ := cmd.Flags().GetBool("cache-only")
+		if err := selectCache(context.GetGlobal(), noCache, cacheOnly); err != nil {
+			return err
+		}
+
+		fixtures, _ := cmd.Flags().GetString("fixtures")
+		record, _ := cmd.Flags().GetString("record")
+		return selectFixtures(context.GetGlobal(), fixtures, record)
+	},
 }
 
 func init() {
+	Cmd.PersistentFlags().String("provider", "", "AI provider: "+strings.Join(ai.Providers(), ", "))
+	Cmd.PersistentFlags().String("model", "", "model ID (default: the provider's default model)")
+	Cmd.PersistentFlags().Bool("no-cache", false, "neither read nor write the response cache")
+	Cmd.PersistentFlags().Bool("cache-only", false, "answer from the response cache only; fail on a miss")
+	Cmd.PersistentFlags().String("fixtures", "", "fixtures file replayed by the fake provider")
+	Cmd.PersistentFlags().String("record", "", "append the provider's replies to this fixtures file")
+
 {%- if "chat" in values.aiFeatures %}
 	Cmd.AddCommand(chatCmd)
+	chatCmd.Flags().Bool("stream", false, "print the response as it is generated")
+	chatCmd.Flags().BoolP("interactive", "i", false, "start an interactive multi-turn session")
+	addGenerationFlags(chatCmd)
+	Cmd.AddCommand(sessionsCmd)
 {%- endif %}
 {%- if "analyze" in values.aiFeatures %}
 	Cmd.AddCommand(analyzeCmd)
+	analyzeCmd.Flags().StringArrayP("file", "f", nil, "read input from a file or glob (repeatable)")
+	analyzeCmd.Flags().String("schema", "", "JSON Schema file; reply with JSON matching it")
+	analyzeCmd.Flags().StringArray("attach", nil, "send an image (PNG, JPEG, GIF, WebP) or PDF with the input (repeatable)")
 {%- endif %}
 {%- if "summarize" in values.aiFeatures %}
 	Cmd.AddCommand(summarizeCmd)
+	summarizeCmd.Flags().StringArrayP("file", "f", nil, "read input from a file or glob (repeatable)")
 {%- endif %}
 {%- if "generate" in values.aiFeatures %}
 	Cmd.AddCommand(generateCmd)
+	addGenerationFlags(generateCmd)
+	generateCmd.Flags().String("schema", "", "JSON Schema file; reply with JSON matching it")
 {%- endif %}
+	Cmd.AddCommand(agentCmd)
+	Cmd.AddCommand(shellCmd)
+	Cmd.AddCommand(usageCmd)
+	Cmd.AddCommand(cacheCmd)
+	Cmd.AddCommand(promptCmd)
+	Cmd.AddCommand(indexCmd)
+	Cmd.AddCommand(askCmd)
+	Cmd.AddCommand(evalCmd)
 	Cmd.AddCommand(modelsCmd)
+	modelsCmd.Flags().String("filter", "", "only list models whose ID or name contains this text")
 }
 
 {%- if "chat" in values.aiFeatures %}
@@ -42,12 +104,31 @@ func init() {
 var chatCmd = &cobra.Command{
 	Use:   "chat [prompt]",
 	Short: "Chat with AI",
-	Args:  cobra.MinimumNArgs(1),
+	Long:  "Chat with AI. Without a prompt, or with -i, starts an interactive session.",
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := generationOptions(cmd)
+		if err != nil {
+			return err
+		}
 		ctx := context.GetGlobal()
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
+		client.SetOptions(opts)
+
+		if interactive, _ := cmd.Flags().GetBool("interactive"); interactive || len(args) == 0 {
+			return runREPL(cmd.Context(), ctx, newSession(client))
+		}
 		prompt := args[0]
+		warnContextWindow(ctx, client, ai.Message{Role: ai.RoleUser, Content: prompt})
+
+		if stream, _ := cmd.Flags().GetBool("stream"); stream {
+			return streamChat(cmd.Context(), ctx, prompt)
+		}
 
-		response, err := ctx.AI().Chat(cmd.Context(), prompt)
+		response, err := client.Chat(cmd.Context(), prompt)
 		if err != nil {
 			return err
 		}
@@ -61,20 +142,18 @@ var chatCmd = &cobra.Command{
 {%- if "analyze" in values.aiFeatures %}
 
 var analyzeCmd = &cobra.Command{
-	Use:   "analyze [text]",
-	Short: "Analyze text with AI",
-	Args:  cobra.MinimumNArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.GetGlobal()
-		text := args[0]
+	Use:   "analyze [text|-]",
+	Short: "Analyze text, images and PDFs with AI",
+	Long: `Analyze text given as arguments, read from files with -f, or piped on stdin.
+Input too large for the model's context window is processed in parts.
 
-		analysis, err := ctx.AI().Analyze(cmd.Context(), text)
-		if err != nil {
-			return err
-		}
-
-		ctx.Output.Info(analysis)
-		return nil
+Images and PDFs, e.g. dashboard screenshots and incident reports, are sent
+with --attach; the text is then optional.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, _ := cmd.Flags().GetStringArray("file")
+		attach, _ := cmd.Flags().GetStringArray("attach")
+		schema, _ := cmd.Flags().GetString("schema")
+		return analyze(cmd.Context(), context.GetGlobal(), args, files, attach, schema)
 	},
 }
 {%- endif %}
@@ -82,14 +161,23 @@ var analyzeCmd = &cobra.Command{
 {%- if "summarize" in values.aiFeatures %}
 
 var summarizeCmd = &cobra.Command{
-	Use:   "summarize [text]",
+	Use:   "summarize [text|-]",
 	Short: "Summarize text with AI",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Summarize text given as arguments, read from files with -f, or piped on stdin.
+Input too large for the model's context window is processed in parts.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx := context.GetGlobal()
-		text := args[0]
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
+		files, _ := cmd.Flags().GetStringArray("file")
+		text, err := readInput(args, files)
+		if err != nil {
+			return err
+		}
 
-		summary, err := ctx.AI().Summarize(cmd.Context(), text)
+		summary, err := client.Summarize(cmd.Context(), text)
 		if err != nil {
 			return err
 		}
@@ -107,10 +195,31 @@ var generateCmd = &cobra.Command{
 	Short: "Generate content with AI",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := generationOptions(cmd)
+		if err != nil {
+			return err
+		}
 		ctx := context.GetGlobal()
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
 		prompt := args[0]
+		warnContextWindow(ctx, client, ai.Message{Role: ai.RoleUser, Content: prompt})
+
+		if path, _ := cmd.Flags().GetString("schema"); path != "" {
+			schema, err := ai.LoadSchema(path)
+			if err != nil {
+				return err
+			}
+			value, err := client.GenerateJSON(cmd.Context(), prompt, schema, opts)
+			if err != nil {
+				return err
+			}
+			return ctx.Output.Data(value, "")
+		}
 
-		content, err := ctx.AI().Generate(cmd.Context(), prompt, nil)
+		content, err := client.Generate(cmd.Context(), prompt, opts)
 		if err != nil {
 			return err
 		}
@@ -124,22 +233,89 @@ var generateCmd = &cobra.Command{
 var modelsCmd = &cobra.Command{
 	Use:   "models",
 	Short: "List available AI models",
+	Long:  "List the models in the provider's catalog. The configured model is marked.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ctx := context.GetGlobal()
-		ctx.Output.Info("Current model: " + ctx.Config.AI.Model)
-		return nil
+		filter, _ := cmd.Flags().GetString("filter")
+		return listModels(cmd.Context(), context.GetGlobal(), filter)
 	},
 }
 
+{%- if "chat" in values.aiFeatures or "generate" in values.aiFeatures %}
+
+// addGenerationFlags registers the flags read by generationOptions
+func addGenerationFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("max-tokens", 0, "maximum number of tokens to generate")
+	cmd.Flags().Float64("temperature", 0, "sampling temperature (0 for the most deterministic output)")
+	cmd.Flags().Float64("top-p", 0, "nucleus sampling probability")
+	cmd.Flags().StringArray("stop", nil, "stop generating at this sequence (repeatable)")
+	cmd.Flags().String("system", "", "system prompt")
+}
+
+// generationOptions reads the generation flags. Flags that were not given
+// leave the configured options in place. Values are checked against the
+// rules of the config keys they override.
+func generationOptions(cmd *cobra.Command) (ai.GenerationOptions, error) {
+	var opts ai.GenerationOptions
+	opts.MaxTokens, _ = cmd.Flags().GetInt("max-tokens")
+	opts.Stop, _ = cmd.Flags().GetStringArray("stop")
+	opts.System, _ = cmd.Flags().GetString("system")
+	if cmd.Flags().Changed("temperature") {
+		temperature, _ := cmd.Flags().GetFloat64("temperature")
+		opts.Temperature = ai.Float(temperature)
+	}
+	if cmd.Flags().Changed("top-p") {
+		topP, _ := cmd.Flags().GetFloat64("top-p")
+		opts.TopP = ai.Float(topP)
+	}
+	return opts, checkGenerationOptions(opts)
+}
+{%- endif %}
+
 {%- elif values.cliFramework == "urfave" %}
 
 // Cmd is the root AI command
 var Cmd = &cli.Command{
 	Name:  "ai",
-	Usage: "AI-powered operations using {{values.aiProvider}}",
+	Usage: "AI-powered operations (default provider: ${{values.aiProvider}})",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "provider",
+			Usage: "AI provider: " + strings.Join(ai.Providers(), ", "),
+		},
+		&cli.StringFlag{
+			Name:  "model",
+			Usage: "model ID (default: the provider's default model)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Usage: "neither read nor write the response cache",
+		},
+		&cli.BoolFlag{
+			Name:  "cache-only",
+			Usage: "answer from the response cache only; fail on a miss",
+		},
+		&cli.StringFlag{
+			Name:  "fixtures",
+			Usage: "fixtures file replayed by the fake provider",
+		},
+		&cli.StringFlag{
+			Name:  "record",
+			Usage: "append the provider's replies to this fixtures file",
+		},
+	},
+	Before: func(c *cli.Context) error {
+		if err := selectProvider(context.GetGlobal(), c.String("provider"), c.String("model")); err != nil {
+			return err
+		}
+		if err := selectCache(context.GetGlobal(), c.Bool("no-cache"), c.Bool("cache-only")); err != nil {
+			return err
+		}
+		return selectFixtures(context.GetGlobal(), c.String("fixtures"), c.String("record"))
+	},
 	Subcommands: []*cli.Command{
 {%- if "chat" in values.aiFeatures %}
 		chatCmd,
+		sessionsCmd,
 {%- endif %}
 {%- if "analyze" in values.aiFeatures %}
 		analyzeCmd,
@@ -150,6 +326,14 @@ var Cmd = &cli.Command{
 {%- if "generate" in values.aiFeatures %}
 		generateCmd,
 {%- endif %}
+		agentCmd,
+		shellCmd,
+		usageCmd,
+		cacheCmd,
+		promptCmd,
+		indexCmd,
+		askCmd,
+		evalCmd,
 		modelsCmd,
 	},
 }
@@ -158,17 +342,42 @@ var Cmd = &cli.Command{
 
 var chatCmd = &cli.Command{
 	Name:      "chat",
-	Usage:     "Chat with AI",
+	Usage:     "Chat with AI (interactive session when no prompt is given)",
 	ArgsUsage: "[prompt]",
+	Flags: append([]cli.Flag{
+		&cli.BoolFlag{
+			Name:  "stream",
+			Usage: "print the response as it is generated",
+		},
+		&cli.BoolFlag{
+			Name:    "interactive",
+			Aliases: []string{"i"},
+			Usage:   "start an interactive multi-turn session",
+		},
+	}, generationFlags()...),
 	Action: func(c *cli.Context) error {
-		if c.NArg() < 1 {
-			return cli.ShowSubcommandHelp(c)
+		opts, err := generationOptions(c)
+		if err != nil {
+			return err
 		}
-
 		ctx := context.GetGlobal()
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
+		client.SetOptions(opts)
+
+		if c.Bool("interactive") || c.NArg() < 1 {
+			return runREPL(c.Context, ctx, newSession(client))
+		}
 		prompt := c.Args().First()
+		warnContextWindow(ctx, client, ai.Message{Role: ai.RoleUser, Content: prompt})
 
-		response, err := ctx.AI().Chat(c.Context, prompt)
+		if c.Bool("stream") {
+			return streamChat(c.Context, ctx, prompt)
+		}
+
+		response, err := client.Chat(c.Context, prompt)
 		if err != nil {
 			return err
 		}
@@ -183,23 +392,25 @@ var chatCmd = &cli.Command{
 
 var analyzeCmd = &cli.Command{
 	Name:      "analyze",
-	Usage:     "Analyze text with AI",
-	ArgsUsage: "[text]",
+	Usage:     "Analyze text from arguments, files (-f) or stdin (-), and images or PDFs (--attach), with AI",
+	ArgsUsage: "[text|-]",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Usage:   "read input from a file or glob (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "schema",
+			Usage: "JSON Schema file; reply with JSON matching it",
+		},
+		&cli.StringSliceFlag{
+			Name:  "attach",
+			Usage: "send an image (PNG, JPEG, GIF, WebP) or PDF with the input (repeatable)",
+		},
+	},
 	Action: func(c *cli.Context) error {
-		if c.NArg() < 1 {
-			return cli.ShowSubcommandHelp(c)
-		}
-
-		ctx := context.GetGlobal()
-		text := c.Args().First()
-
-		analysis, err := ctx.AI().Analyze(c.Context, text)
-		if err != nil {
-			return err
-		}
-
-		ctx.Output.Info(analysis)
-		return nil
+		return analyze(c.Context, context.GetGlobal(), c.Args().Slice(), c.StringSlice("file"), c.StringSlice("attach"), c.String("schema"))
 	},
 }
 {%- endif %}
@@ -208,17 +419,27 @@ var analyzeCmd = &cli.Command{
 
 var summarizeCmd = &cli.Command{
 	Name:      "summarize",
-	Usage:     "Summarize text with AI",
-	ArgsUsage: "[text]",
+	Usage:     "Summarize text from arguments, files (-f) or stdin (-) with AI",
+	ArgsUsage: "[text|-]",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "file",
+			Aliases: []string{"f"},
+			Usage:   "read input from a file or glob (repeatable)",
+		},
+	},
 	Action: func(c *cli.Context) error {
-		if c.NArg() < 1 {
-			return cli.ShowSubcommandHelp(c)
-		}
-
 		ctx := context.GetGlobal()
-		text := c.Args().First()
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
+		text, err := readInput(c.Args().Slice(), c.StringSlice("file"))
+		if err != nil {
+			return err
+		}
 
-		summary, err := ctx.AI().Summarize(c.Context, text)
+		summary, err := client.Summarize(c.Context, text)
 		if err != nil {
 			return err
 		}
@@ -235,15 +456,40 @@ var generateCmd = &cli.Command{
 	Name:      "generate",
 	Usage:     "Generate content with AI",
 	ArgsUsage: "[prompt]",
+	Flags: append(generationFlags(), &cli.StringFlag{
+		Name:  "schema",
+		Usage: "JSON Schema file; reply with JSON matching it",
+	}),
 	Action: func(c *cli.Context) error {
 		if c.NArg() < 1 {
 			return cli.ShowSubcommandHelp(c)
 		}
+		opts, err := generationOptions(c)
+		if err != nil {
+			return err
+		}
 
 		ctx := context.GetGlobal()
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
 		prompt := c.Args().First()
+		warnContextWindow(ctx, client, ai.Message{Role: ai.RoleUser, Content: prompt})
+
+		if path := c.String("schema"); path != "" {
+			schema, err := ai.LoadSchema(path)
+			if err != nil {
+				return err
+			}
+			value, err := client.GenerateJSON(c.Context, prompt, schema, opts)
+			if err != nil {
+				return err
+			}
+			return ctx.Output.Data(value, "")
+		}
 
-		content, err := ctx.AI().Generate(c.Context, prompt, nil)
+		content, err := client.Generate(c.Context, prompt, opts)
 		if err != nil {
 			return err
 		}
@@ -256,13 +502,284 @@ var generateCmd = &cli.Command{
 
 var modelsCmd = &cli.Command{
 	Name:  "models",
-	Usage: "List available AI models",
+	Usage: "List the models in the provider's catalog",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "filter",
+			Usage: "only list models whose ID or name contains this text",
+		},
+	},
 	Action: func(c *cli.Context) error {
-		ctx := context.GetGlobal()
-		ctx.Output.Info("Current model: " + ctx.Config.AI.Model)
-		return nil
+		return listModels(c.Context, context.GetGlobal(), c.String("filter"))
 	},
 }
+
+{%- if "chat" in values.aiFeatures or "generate" in values.aiFeatures %}
+
+// generationFlags returns the flags read by generationOptions
+func generationFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "max-tokens",
+			Usage: "maximum number of tokens to generate",
+		},
+		&cli.Float64Flag{
+			Name:  "temperature",
+			Usage: "sampling temperature (0 for the most deterministic output)",
+		},
+		&cli.Float64Flag{
+			Name:  "top-p",
+			Usage: "nucleus sampling probability",
+		},
+		&cli.StringSliceFlag{
+			Name:  "stop",
+			Usage: "stop generating at this sequence (repeatable)",
+		},
+		&cli.StringFlag{
+			Name:  "system",
+			Usage: "system prompt",
+		},
+	}
+}
+
+// generationOptions reads the generation flags. Flags that were not given
+// leave the configured options in place. Values are checked against the
+// rules of the config keys they override.
+func generationOptions(c *cli.Context) (ai.GenerationOptions, error) {
+	opts := ai.GenerationOptions{
+		MaxTokens: c.Int("max-tokens"),
+		Stop:      c.StringSlice("stop"),
+		System:    c.String("system"),
+	}
+	if c.IsSet("temperature") {
+		opts.Temperature = ai.Float(c.Float64("temperature"))
+	}
+	if c.IsSet("top-p") {
+		opts.TopP = ai.Float(c.Float64("top-p"))
+	}
+	return opts, checkGenerationOptions(opts)
+}
+{%- endif %}
+{%- endif %}
+
+{%- if "chat" in values.aiFeatures or "generate" in values.aiFeatures %}
+
+// checkGenerationOptions checks the generation flags against the rules of
+// the config keys they override
+func checkGenerationOptions(opts ai.GenerationOptions) error {
+	if err := config.CheckValue("ai.max_tokens", opts.MaxTokens); err != nil {
+		return fmt.Errorf("invalid --max-tokens %d: %w", opts.MaxTokens, err)
+	}
+	if opts.Temperature != nil {
+		if err := config.CheckValue("ai.temperature", *opts.Temperature); err != nil {
+			return fmt.Errorf("invalid --temperature %g: %w", *opts.Temperature, err)
+		}
+	}
+	if opts.TopP != nil {
+		if err := config.CheckValue("ai.top_p", *opts.TopP); err != nil {
+			return fmt.Errorf("invalid --top-p %g: %w", *opts.TopP, err)
+		}
+	}
+	return nil
+}
+{%- endif %}
+
+// selectProvider applies --provider and --model overrides before the AI
+// client is first created
+func selectProvider(ctx *context.Context, provider, model string) error {
+	if provider != "" && provider != ctx.Config.AI.Provider {
+		if !ai.IsProvider(provider) {
+			return fmt.Errorf("unknown AI provider %q (available: %s)", provider, strings.Join(ai.Providers(), ", "))
+		}
+		ctx.Config.AI.Provider = provider
+		// A model configured for another provider is not valid here
+		ctx.Config.AI.Model = ""
+	}
+	if model != "" {
+		ctx.Config.AI.Model = model
+	}
+	return nil
+}
+
+// selectCache applies --no-cache and --cache-only before the AI client is
+// first created
+func selectCache(ctx *context.Context, noCache, cacheOnly bool) error {
+	if noCache && cacheOnly {
+		return fmt.Errorf("--no-cache and --cache-only cannot be used together")
+	}
+	if noCache {
+		ctx.Config.AI.Cache.Enabled = false
+		ctx.Config.AI.Cache.Only = false
+	}
+	if cacheOnly {
+		ctx.Config.AI.Cache.Only = true
+	}
+	return nil
+}
+
+// selectFixtures applies --fixtures and --record before the AI client is
+// first created
+func selectFixtures(ctx *context.Context, fixtures, record string) error {
+	if fixtures != "" {
+		ctx.Config.AI.Fixtures = fixtures
+	}
+	if record != "" {
+		ctx.Config.AI.Record = record
+	}
+	if ctx.Config.AI.Record != "" && ctx.Config.AI.Provider == "fake" {
+		return fmt.Errorf("recording needs a real provider, not fake")
+	}
+	return nil
+}
+
+// listModels prints the provider's model catalog, marking the configured model
+func listModels(stdctx stdcontext.Context, ctx *context.Context, filter string) error {
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	models, err := client.ListModels(stdctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+
+	current := client.Model()
+	rows := make([]map[string]any, 0, len(models))
+	for _, m := range models {
+		if !m.MatchesFilter(filter) {
+			continue
+		}
+
+		window := "unknown"
+		if m.ContextWindow > 0 {
+			window = fmt.Sprintf("%d", m.ContextWindow)
+		}
+		rows = append(rows, map[string]any{
+			"id":             m.ID,
+			"context_window": window,
+			"modalities":     strings.Join(m.InputModalities, ",") + " -> " + strings.Join(m.OutputModalities, ","),
+			"configured":     isModel(m, current),
+		})
+	}
+
+	return ctx.Output.Data(rows, fmt.Sprintf("Models (%s)", client.Provider()))
+}
+
+// isModel reports whether m is the named model. Ollama lists models with a
+// tag, so an untagged name matches the latest tag.
+func isModel(m ai.Model, name string) bool {
+	return m.ID == name || m.Name == name || m.ID == name+":latest"
+}
+
+{%- if "analyze" in values.aiFeatures %}
+
+// analyze analyzes the input and attachments, replying with JSON matching
+// the schema at schemaPath if one is given. With attachments, text input is
+// optional.
+func analyze(stdctx stdcontext.Context, ctx *context.Context, args, files, attach []string, schemaPath string) error {
+	attachments := make([]ai.Attachment, 0, len(attach))
+	for _, path := range attach {
+		a, err := ai.LoadAttachment(path)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, a)
+	}
+
+	var text string
+	if len(attachments) == 0 || len(args) > 0 || len(files) > 0 || stdinPiped() {
+		var err error
+		if text, err = readInput(args, files); err != nil {
+			return err
+		}
+	}
+
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+
+	if schemaPath != "" {
+		schema, err := ai.LoadSchema(schemaPath)
+		if err != nil {
+			return err
+		}
+		value, err := client.AnalyzeJSON(stdctx, text, schema, attachments...)
+		if err != nil {
+			return err
+		}
+		return ctx.Output.Data(value, "Analysis")
+	}
+
+	analysis, err := client.Analyze(stdctx, text, attachments...)
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Info(analysis)
+	return nil
+}
+{%- endif %}
+
+{%- if "chat" in values.aiFeatures or "generate" in values.aiFeatures %}
+
+// warnContextWindow warns when messages are unlikely to fit the model's
+// context window, which makes the provider reject the request
+func warnContextWindow(ctx *context.Context, client *ai.Client, messages ...ai.Message) {
+	tokens, window := client.CountTokens(messages), client.ContextWindow()
+	if tokens > window {
+		ctx.Output.Warning(fmt.Sprintf("The input is about %d tokens, more than the %d-token context window of %s; the request will likely fail (set ai.context_window if the model's window is larger)",
+			tokens, window, client.Model()))
+	}
+}
+{%- endif %}
+
+{%- if "chat" in values.aiFeatures %}
+
+// streamChat prints a chat response chunk by chunk as it arrives
+func streamChat(stdctx stdcontext.Context, ctx *context.Context, prompt string) error {
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	chunks, err := client.ChatStream(stdctx, prompt)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := printStream(ctx, chunks); err != nil {
+		return err
+	}
+	return stdctx.Err()
+}
+
+// newSession starts a session for the client's provider, model and system
+// prompt
+func newSession(client *ai.Client) *session.Session {
+	return session.New(client.Provider(), client.Model(), client.Options().System)
+}
+
+// printStream prints chunks as they arrive and returns the accumulated text
+// and the token usage the provider reported
+func printStream(ctx *context.Context, chunks <-chan ai.Chunk) (string, ai.Usage, error) {
+	var text strings.Builder
+	var usage ai.Usage
+	defer ctx.Output.StreamEnd()
+
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			return text.String(), usage, chunk.Err
+		}
+		if chunk.Usage != nil {
+			usage.Add(*chunk.Usage)
+		}
+		ctx.Output.Stream(chunk.Text)
+		text.WriteString(chunk.Text)
+	}
+
+	return text.String(), usage, nil
+}
 {%- endif %}
 {%- else %}
 // Package ai is a placeholder when AI is not enabled
@@ -0,0 +1,147 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"fmt"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the AI response cache",
+	Long: `Manage the AI response cache.
+
+With ai.cache.enabled, responses are stored on disk keyed by provider, model,
+options and messages, and repeated requests are answered from the cache.`,
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd, cacheClearCmd)
+
+	cacheClearCmd.Flags().BoolP("force", "f", false, "clear without confirmation")
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the size of the response cache",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cacheStats(context.GetGlobal())
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached response",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return clearCache(context.GetGlobal(), force)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var cacheCmd = &cli.Command{
+	Name:  "cache",
+	Usage: "Manage the AI response cache",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "stats",
+			Usage: "Show the size of the response cache",
+			Action: func(c *cli.Context) error {
+				return cacheStats(context.GetGlobal())
+			},
+		},
+		{
+			Name:  "clear",
+			Usage: "Remove every cached response",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "force",
+					Aliases: []string{"f"},
+					Usage:   "clear without confirmation",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return clearCache(context.GetGlobal(), c.Bool("force"))
+			},
+		},
+	},
+}
+{%- endif %}
+
+func cacheStats(ctx *context.Context) error {
+	cfg := ctx.Config.AI.Cache
+	cache, err := ai.DefaultCache(cfg)
+	if err != nil {
+		return err
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		return err
+	}
+
+	ttl, maxSize := cfg.TTLHours, cfg.MaxSizeMB
+	if ttl <= 0 {
+		ttl = int(ai.DefaultCacheTTL.Hours())
+	}
+	if maxSize <= 0 {
+		maxSize = ai.DefaultCacheMaxSize >> 20
+	}
+	return ctx.Output.Data(map[string]any{
+		"enabled":     cfg.Enabled,
+		"dir":         stats.Dir,
+		"entries":     stats.Entries,
+		"size":        fmt.Sprintf("%.1f MB", float64(stats.Bytes)/(1<<20)),
+		"ttl_hours":   ttl,
+		"max_size_mb": maxSize,
+	}, "AI response cache")
+}
+
+func clearCache(ctx *context.Context, force bool) error {
+	cache, err := ai.DefaultCache(ctx.Config.AI.Cache)
+	if err != nil {
+		return err
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		return err
+	}
+	if stats.Entries == 0 {
+		ctx.Output.Info("The response cache is empty")
+		return nil
+	}
+
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would remove %d cached responses from %s", stats.Entries, stats.Dir)
+		return nil
+	}
+
+	if !force && !ctx.Confirm(fmt.Sprintf("Remove %d cached responses?", stats.Entries), false) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+
+	removed, err := cache.Clear()
+	if err != nil {
+		return err
+	}
+	ctx.Output.Success(fmt.Sprintf("Removed %d cached responses", removed))
+	return nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,115 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	stdcontext "context"
+	"fmt"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/config"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+	"github.com/fast-ish/${{values.name}}/internal/eval"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var evalCmd = &cobra.Command{
+	Use:   "eval <suite.yaml>",
+	Short: "Run a prompt evaluation suite and report the results",
+	Long: `Run a prompt evaluation suite and report the results.
+
+Each case is run through the suite's prompt on each of its models, and the
+output graded by exact match, regular expression, JSON Schema or a judge
+model. The report is a table, or JSON, YAML or JUnit XML with --output. The
+command fails if any case fails.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runEval(cmd.Context(), context.GetGlobal(), args[0])
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var evalCmd = &cli.Command{
+	Name:      "eval",
+	Usage:     "Run a prompt evaluation suite and report the results (fails if any case fails)",
+	ArgsUsage: "<suite.yaml>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return cli.ShowSubcommandHelp(c)
+		}
+		return runEval(c.Context, context.GetGlobal(), c.Args().First())
+	},
+}
+{%- endif %}
+
+// runEval runs the suite at path and prints the report
+func runEval(stdctx stdcontext.Context, ctx *context.Context, path string) error {
+	suite, err := eval.Load(path)
+	if err != nil {
+		return err
+	}
+
+	var clients []*ai.Client
+	if len(suite.Models) == 0 {
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
+		clients = append(clients, client)
+	}
+	for _, t := range suite.Models {
+		client, err := ctx.NewAI(targetConfig(ctx.Config.AI, t))
+		if err != nil {
+			return err
+		}
+		clients = append(clients, client)
+	}
+	if suite.Prompt != "" {
+		if _, err := clients[0].Prompts().Get(suite.Prompt); err != nil {
+			return err
+		}
+	}
+
+	judge := clients[0]
+	if suite.Judge != nil && suite.NeedsJudge() {
+		if judge, err = ctx.NewAI(targetConfig(ctx.Config.AI, *suite.Judge)); err != nil {
+			return err
+		}
+	}
+
+	report, err := eval.Run(stdctx, suite, clients, judge)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Output.Data(report, "Evaluation: "+suite.Name); err != nil {
+		return err
+	}
+
+	if failed := report.Failed(); len(failed) > 0 {
+		return fmt.Errorf("%d of %d results failed (passed: %s)", len(failed), len(report.Results), report.Summary())
+	}
+	return nil
+}
+
+// targetConfig returns cfg changed to select the target's provider and
+// model. Switching provider without naming a model selects its default.
+func targetConfig(cfg config.AIConfig, t eval.Target) config.AIConfig {
+	if t.Provider != "" && t.Provider != cfg.Provider {
+		cfg.Provider = t.Provider
+		cfg.Model = ""
+	}
+	if t.Model != "" {
+		cfg.Model = t.Model
+	}
+	return cfg
+}
+{%- else %}
+package ai
+{%- endif %}
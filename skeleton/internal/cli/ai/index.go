@@ -0,0 +1,203 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/context"
+	"github.com/fast-ish/${{values.name}}/internal/index"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var indexCmd = &cobra.Command{
+	Use:   "index <path>...",
+	Short: "Index local documents for ai ask",
+	Long: `Index local documents for ai ask.
+
+Files are split into chunks and embedded with the provider's embedding model
+(ai.embedding_model). Directories are walked for ` + strings.Join(index.Extensions, ", ") + ` files.
+Files unchanged since they were last indexed are skipped, and deleted files
+are dropped.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		rebuild, _ := cmd.Flags().GetBool("rebuild")
+		return indexFiles(cmd.Context(), context.GetGlobal(), name, args, rebuild)
+	},
+}
+
+var askCmd = &cobra.Command{
+	Use:   "ask <question>",
+	Short: "Answer a question from indexed documents, with citations",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("index")
+		topK, _ := cmd.Flags().GetInt("top-k")
+		return ask(cmd.Context(), context.GetGlobal(), name, strings.Join(args, " "), topK)
+	},
+}
+
+func init() {
+	indexCmd.Flags().String("name", index.DefaultName, "name of the index")
+	indexCmd.Flags().Bool("rebuild", false, "discard the index and embed every file again")
+
+	askCmd.Flags().String("index", index.DefaultName, "name of the index to search")
+	askCmd.Flags().IntP("top-k", "k", 5, "number of excerpts to answer from")
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var indexCmd = &cli.Command{
+	Name:      "index",
+	Usage:     "Index local documents for ai ask",
+	ArgsUsage: "<path>...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Value: index.DefaultName,
+			Usage: "name of the index",
+		},
+		&cli.BoolFlag{
+			Name:  "rebuild",
+			Usage: "discard the index and embed every file again",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return fmt.Errorf("expected at least one path")
+		}
+		return indexFiles(c.Context, context.GetGlobal(), c.String("name"), c.Args().Slice(), c.Bool("rebuild"))
+	},
+}
+
+var askCmd = &cli.Command{
+	Name:      "ask",
+	Usage:     "Answer a question from indexed documents, with citations",
+	ArgsUsage: "<question>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "index",
+			Value: index.DefaultName,
+			Usage: "name of the index to search",
+		},
+		&cli.IntFlag{
+			Name:    "top-k",
+			Aliases: []string{"k"},
+			Value:   5,
+			Usage:   "number of excerpts to answer from",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return fmt.Errorf("expected a question")
+		}
+		return ask(c.Context, context.GetGlobal(), c.String("index"), strings.Join(c.Args().Slice(), " "), c.Int("top-k"))
+	},
+}
+{%- endif %}
+
+func indexFiles(stdctx stdcontext.Context, ctx *context.Context, name string, paths []string, rebuild bool) error {
+	idx, err := index.Load(name)
+	if rebuild || errors.Is(err, index.ErrNotFound) {
+		idx, err = index.New(name), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	changes, err := idx.Scan(paths)
+	if err != nil {
+		return err
+	}
+	if changes.Empty() {
+		ctx.Output.Info(fmt.Sprintf("Index %q is up to date (%d files)", name, changes.Unchanged))
+		return nil
+	}
+
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would index %d files into %q, skip %d unchanged and drop %d removed",
+			len(changes.Changed), name, changes.Unchanged, len(changes.Removed))
+		return nil
+	}
+
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	chunks, err := idx.Update(stdctx, client, changes)
+	if err != nil {
+		return err
+	}
+	if err := idx.Save(); err != nil {
+		return err
+	}
+
+	ctx.Output.Success(fmt.Sprintf("Indexed %d files (%d chunks) into %q; %d unchanged, %d removed",
+		len(changes.Changed), chunks, name, changes.Unchanged, len(changes.Removed)))
+	return nil
+}
+
+func ask(stdctx stdcontext.Context, ctx *context.Context, name, question string, topK int) error {
+	if topK < 1 {
+		return fmt.Errorf("--top-k must be at least 1")
+	}
+
+	idx, err := index.Load(name)
+	if errors.Is(err, index.ErrNotFound) {
+		return fmt.Errorf("%w (run `${{values.name}} ai index --name %s <path>...` first)", err, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	matches, err := idx.Search(stdctx, client, question, topK)
+	if err != nil {
+		return err
+	}
+
+	var excerpts strings.Builder
+	for i, m := range matches {
+		fmt.Fprintf(&excerpts, "[%d] %s\n%s\n\n", i+1, m.Citation(), m.Text)
+	}
+	prompt, err := client.Prompts().Render("ask", map[string]string{
+		"Question": question,
+		"Text":     excerpts.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	answer, err := client.Chat(stdctx, prompt)
+	if err != nil {
+		return err
+	}
+	ctx.Output.Info(answer)
+
+	sources := make([]map[string]any, 0, len(matches))
+	for i, m := range matches {
+		sources = append(sources, map[string]any{
+			"ref":    fmt.Sprintf("[%d]", i+1),
+			"source": m.Citation(),
+			"score":  math.Round(float64(m.Score)*1000) / 1000,
+		})
+	}
+	return ctx.Output.Data(sources, "Sources")
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,99 @@
+{%- if values.aiProvider != "none" and ("analyze" in values.aiFeatures or "summarize" in values.aiFeatures) %}
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// input is one named piece of text read for a command
+type input struct {
+	name string
+	text string
+}
+
+// readInput collects the text for a command from its arguments, files and
+// stdin. Each pattern may be a file path or a glob. An argument of "-"
+// reads stdin, as does piping into the command without other input.
+func readInput(args, patterns []string) (string, error) {
+	var inputs []input
+
+	var words []string
+	for _, arg := range args {
+		if arg != "-" {
+			words = append(words, arg)
+			continue
+		}
+		text, err := readStdin()
+		if err != nil {
+			return "", err
+		}
+		inputs = append(inputs, input{name: "stdin", text: text})
+	}
+	if len(words) > 0 {
+		inputs = append(inputs, input{name: "arguments", text: strings.Join(words, " ")})
+	}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return "", fmt.Errorf("no files match %q", pattern)
+		}
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read input: %w", err)
+			}
+			inputs = append(inputs, input{name: path, text: string(data)})
+		}
+	}
+
+	if len(inputs) == 0 && stdinPiped() {
+		text, err := readStdin()
+		if err != nil {
+			return "", err
+		}
+		inputs = append(inputs, input{name: "stdin", text: text})
+	}
+
+	switch len(inputs) {
+	case 0:
+		return "", errors.New("no input: pass text, -f FILE, or - to read stdin")
+	case 1:
+		if strings.TrimSpace(inputs[0].text) == "" {
+			return "", fmt.Errorf("input from %s is empty", inputs[0].name)
+		}
+		return inputs[0].text, nil
+	}
+
+	// Label each part so the model can tell the sources apart
+	var text strings.Builder
+	for _, in := range inputs {
+		fmt.Fprintf(&text, "==> %s <==\n%s\n\n", in.name, strings.TrimRight(in.text, "\n"))
+	}
+	return text.String(), nil
+}
+
+func readStdin() (string, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return string(data), nil
+}
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,188 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	stdcontext "context"
+	"fmt"
+	"os"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "List, show and run prompt templates",
+	Long: `List, show and run prompt templates.
+
+Prompts are Go text/template templates. The built-in prompts used by the AI
+commands can be overridden, and new ones added, by *.tmpl files in
+~/.${{values.name}}/prompts or by ai.prompts in config.`,
+}
+
+func init() {
+	promptCmd.AddCommand(promptListCmd, promptShowCmd, promptRunCmd)
+
+	promptRunCmd.Flags().StringArray("var", nil, "template variable as key=value, or key=@file to read a file (repeatable)")
+}
+
+var promptListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available prompts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listPrompts(context.GetGlobal())
+	},
+}
+
+var promptShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a prompt's template",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showPrompt(context.GetGlobal(), args[0])
+	},
+}
+
+var promptRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Render a prompt and send it to the AI",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vars, _ := cmd.Flags().GetStringArray("var")
+		return runPrompt(cmd.Context(), context.GetGlobal(), args[0], vars)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var promptCmd = &cli.Command{
+	Name:  "prompt",
+	Usage: "List, show and run prompt templates",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "List the available prompts",
+			Action: func(c *cli.Context) error {
+				return listPrompts(context.GetGlobal())
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "Show a prompt's template",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected a prompt name")
+				}
+				return showPrompt(context.GetGlobal(), c.Args().First())
+			},
+		},
+		{
+			Name:      "run",
+			Usage:     "Render a prompt and send it to the AI",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "var",
+					Usage: "template variable as key=value, or key=@file to read a file (repeatable)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected a prompt name")
+				}
+				return runPrompt(c.Context, context.GetGlobal(), c.Args().First(), c.StringSlice("var"))
+			},
+		},
+	},
+}
+{%- endif %}
+
+func listPrompts(ctx *context.Context) error {
+	prompts, err := ai.LoadPrompts(ctx.Config.AI)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]map[string]any, 0, len(prompts.List()))
+	for _, p := range prompts.List() {
+		rows = append(rows, map[string]any{
+			"name":        p.Name,
+			"description": p.Description,
+			"source":      p.Source,
+			"variables":   strings.Join(p.Variables(), ", "),
+		})
+	}
+	return ctx.Output.Data(rows, "Prompts")
+}
+
+func showPrompt(ctx *context.Context, name string) error {
+	prompts, err := ai.LoadPrompts(ctx.Config.AI)
+	if err != nil {
+		return err
+	}
+
+	p, err := prompts.Get(name)
+	if err != nil {
+		return err
+	}
+	return ctx.Output.Data(p, p.Name)
+}
+
+func runPrompt(stdctx stdcontext.Context, ctx *context.Context, name string, vars []string) error {
+	values, err := parseVars(vars)
+	if err != nil {
+		return err
+	}
+
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	prompt, err := client.Prompts().Render(name, values)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Chat(stdctx, prompt)
+	if err != nil {
+		return err
+	}
+
+	ctx.Output.Info(response)
+	return nil
+}
+
+// parseVars parses key=value pairs. A value of @path is replaced by the
+// contents of the file.
+func parseVars(vars []string) (map[string]string, error) {
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --var %q: expected key=value", v)
+		}
+		if path, isFile := strings.CutPrefix(value, "@"); isFile {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read --var %s: %w", key, err)
+			}
+			value = string(data)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,148 @@
+{%- if values.aiProvider != "none" and "chat" in values.aiFeatures %}
+package ai
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+	"github.com/fast-ish/${{values.name}}/internal/session"
+)
+
+const replHelp = `Commands:
+  /reset          clear the conversation history
+  /model [name]   show or switch the model
+  /save [file]    save the session now, or export the transcript to a file
+  /help           show this help
+  /exit           end the session`
+
+// runREPL runs an interactive multi-turn chat session until the user exits.
+// The session is saved to the store after every completed turn. The session
+// keeps the full transcript; only the history sent to the model is compacted.
+func runREPL(stdctx stdcontext.Context, ctx *context.Context, sess *session.Session) error {
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	store, err := session.DefaultStore()
+	if err != nil {
+		return err
+	}
+	history := append([]ai.Message(nil), sess.Messages...)
+
+	ctx.Output.Info(fmt.Sprintf("Chatting with %s (session %s). Type /help for commands, /exit to quit.", client.Model(), sess.ID))
+	for {
+		line, err := ctx.Output.Prompt("you")
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			done, err := replCommand(ctx, client, store, sess, &history, line)
+			if err != nil {
+				ctx.Output.Error(err.Error())
+			}
+			if done {
+				return nil
+			}
+			continue
+		}
+
+		turn := ai.Message{Role: ai.RoleUser, Content: line}
+		messages, compacted, err := client.Compact(stdctx, append(history, turn))
+		if err != nil {
+			ctx.Output.Error(err.Error())
+			continue
+		}
+		if compacted {
+			ctx.Output.Info(fmt.Sprintf("Summarized earlier turns to fit the context window of %s", client.Model()))
+		}
+		warnContextWindow(ctx, client, messages...)
+
+		chunks, err := client.ConverseStream(stdctx, messages)
+		if err != nil {
+			ctx.Output.Error(err.Error())
+			continue
+		}
+
+		reply, usage, err := printStream(ctx, chunks)
+		if stdctx.Err() != nil {
+			return stdctx.Err()
+		}
+		if err != nil {
+			ctx.Output.Error(err.Error())
+			continue
+		}
+
+		answer := ai.Message{Role: ai.RoleAssistant, Content: reply}
+		history = append(messages, answer)
+		sess.Append(turn, answer)
+		sess.Usage.Add(usage)
+		if err := store.Save(sess); err != nil {
+			ctx.Output.Warning(err.Error())
+		}
+	}
+}
+
+// replCommand handles a slash command and reports whether the session should
+// end. history is the possibly compacted history sent to the model.
+func replCommand(ctx *context.Context, client *ai.Client, store *session.Store, sess *session.Session, history *[]ai.Message, line string) (bool, error) {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "/exit", "/quit":
+		return true, nil
+	case "/help":
+		ctx.Output.Info(replHelp)
+	case "/reset":
+		sess.Reset()
+		*history = append([]ai.Message(nil), sess.Messages...)
+		ctx.Output.Success("Conversation cleared")
+	case "/model":
+		if len(args) == 0 {
+			ctx.Output.Info("Current model: " + client.Model())
+			return false, nil
+		}
+		client.SetModel(args[0])
+		sess.Model = args[0]
+		ctx.Output.Success("Switched model to " + args[0])
+	case "/save":
+		if len(args) == 0 {
+			if err := store.Save(sess); err != nil {
+				return false, err
+			}
+			ctx.Output.Success("Session saved as " + sess.ID)
+			return false, nil
+		}
+		data, err := json.MarshalIndent(sess, "", "  ")
+		if err != nil {
+			return false, fmt.Errorf("failed to encode transcript: %w", err)
+		}
+		if err := os.WriteFile(args[0], data, 0o600); err != nil {
+			return false, fmt.Errorf("failed to save transcript: %w", err)
+		}
+		ctx.Output.Success("Transcript saved to " + args[0])
+	default:
+		return false, fmt.Errorf("unknown command %s (try /help)", name)
+	}
+
+	return false, nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,292 @@
+{%- if values.aiProvider != "none" and "chat" in values.aiFeatures %}
+package ai
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/context"
+	"github.com/fast-ish/${{values.name}}/internal/session"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved chat sessions",
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsResumeCmd, sessionsDeleteCmd, sessionsExportCmd)
+
+	sessionsDeleteCmd.Flags().BoolP("force", "f", false, "delete without confirmation")
+	sessionsExportCmd.Flags().String("format", "markdown", "export format: markdown, json")
+	sessionsExportCmd.Flags().String("file", "", "write to file instead of stdout")
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved sessions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listSessions(context.GetGlobal())
+	},
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show [id]",
+	Short: "Show a saved session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showSession(context.GetGlobal(), args[0])
+	},
+}
+
+var sessionsResumeCmd = &cobra.Command{
+	Use:   "resume [id]",
+	Short: "Continue a saved session interactively",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return resumeSession(cmd.Context(), context.GetGlobal(), args[0])
+	},
+}
+
+var sessionsDeleteCmd = &cobra.Command{
+	Use:   "delete [id]",
+	Short: "Delete a saved session",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return deleteSession(context.GetGlobal(), args[0], force)
+	},
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export [id]",
+	Short: "Export a session transcript",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		file, _ := cmd.Flags().GetString("file")
+		return exportSession(context.GetGlobal(), args[0], format, file)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var sessionsCmd = &cli.Command{
+	Name:  "sessions",
+	Usage: "Manage saved chat sessions",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "List saved sessions",
+			Action: func(c *cli.Context) error {
+				return listSessions(context.GetGlobal())
+			},
+		},
+		{
+			Name:      "show",
+			Usage:     "Show a saved session",
+			ArgsUsage: "[id]",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowSubcommandHelp(c)
+				}
+				return showSession(context.GetGlobal(), c.Args().First())
+			},
+		},
+		{
+			Name:      "resume",
+			Usage:     "Continue a saved session interactively",
+			ArgsUsage: "[id]",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowSubcommandHelp(c)
+				}
+				return resumeSession(c.Context, context.GetGlobal(), c.Args().First())
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a saved session",
+			ArgsUsage: "[id]",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "force",
+					Aliases: []string{"f"},
+					Usage:   "delete without confirmation",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowSubcommandHelp(c)
+				}
+				return deleteSession(context.GetGlobal(), c.Args().First(), c.Bool("force"))
+			},
+		},
+		{
+			Name:      "export",
+			Usage:     "Export a session transcript",
+			ArgsUsage: "[id]",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "format",
+					Value: "markdown",
+					Usage: "export format: markdown, json",
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "write to file instead of stdout",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return cli.ShowSubcommandHelp(c)
+				}
+				return exportSession(context.GetGlobal(), c.Args().First(), c.String("format"), c.String("file"))
+			},
+		},
+	},
+}
+{%- endif %}
+
+func listSessions(ctx *context.Context) error {
+	store, err := session.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	sessions, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	rows := make([]map[string]any, 0, len(sessions))
+	for _, s := range sessions {
+		rows = append(rows, map[string]any{
+			"id":      s.ID,
+			"title":   s.Title,
+			"model":   s.Model,
+			"turns":   s.Turns(),
+			"tokens":  s.Usage.Total(),
+			"updated": s.UpdatedAt.Local().Format(time.DateTime),
+		})
+	}
+	return ctx.Output.Data(rows, "Sessions")
+}
+
+func showSession(ctx *context.Context, id string) error {
+	store, err := session.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	return ctx.Output.Data(sess, sess.Title)
+}
+
+func resumeSession(stdctx stdcontext.Context, ctx *context.Context, id string) error {
+	store, err := session.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+
+	if sess.Provider != ctx.Config.AI.Provider {
+		ctx.Output.Warning(fmt.Sprintf("Session was recorded with %s, continuing with %s", sess.Provider, ctx.Config.AI.Provider))
+		sess.Provider = ctx.Config.AI.Provider
+	} else if sess.Model != "" {
+		client, err := ctx.AI()
+		if err != nil {
+			return err
+		}
+		client.SetModel(sess.Model)
+	}
+
+	ctx.Output.Info(fmt.Sprintf("Resuming %q (%d turns)", sess.Title, sess.Turns()))
+	return runREPL(stdctx, ctx, sess)
+}
+
+func deleteSession(ctx *context.Context, id string, force bool) error {
+	store, err := session.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would delete session %s (%s)", sess.ID, sess.Title)
+		return nil
+	}
+
+	if !force && !ctx.Confirm(fmt.Sprintf("Delete session %s (%s)?", sess.ID, sess.Title), false) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+
+	if err := store.Delete(sess.ID); err != nil {
+		return err
+	}
+	ctx.Output.Success("Deleted session " + sess.ID)
+	return nil
+}
+
+func exportSession(ctx *context.Context, id, format, file string) error {
+	store, err := session.DefaultStore()
+	if err != nil {
+		return err
+	}
+
+	sess, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch format {
+	case "markdown", "md":
+		data = []byte(sess.Markdown())
+	case "json":
+		data, err = json.MarshalIndent(sess, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode session: %w", err)
+		}
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported export format %q (use markdown or json)", format)
+	}
+
+	if file == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	ctx.Output.Success("Exported session to " + file)
+	return nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,144 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	stdcontext "context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+// shellConfirmation is what the user types to run a high-risk command
+const shellConfirmation = "run"
+
+{%- if values.cliFramework == "cobra" %}
+
+var shellCmd = &cobra.Command{
+	Use:   "shell [task]",
+	Short: "Suggest a shell command for a task and run it",
+	Long: `Suggest a shell command for a task and run it.
+
+The command is shown with an explanation and a risk rating, and runs only
+after confirmation; with --dry-run it is shown but not run. Commands rated
+high risk, which include any that delete data or are hard to undo, must be
+confirmed by typing "` + shellConfirmation + `", even with --force.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shell, _ := cmd.Flags().GetString("shell")
+		force, _ := cmd.Flags().GetBool("force")
+		return runShell(cmd.Context(), context.GetGlobal(), strings.Join(args, " "), shell, force)
+	},
+}
+
+func init() {
+	shellCmd.Flags().String("shell", "", "shell to run the command with (default: $SHELL, or sh)")
+	shellCmd.Flags().BoolP("force", "f", false, "run commands that are not high risk without confirmation")
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var shellCmd = &cli.Command{
+	Name:      "shell",
+	Usage:     "Suggest a shell command for a task and run it (confirmed first)",
+	ArgsUsage: "[task]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "shell",
+			Usage: "shell to run the command with (default: $SHELL, or sh)",
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "run commands that are not high risk without confirmation",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 1 {
+			return cli.ShowSubcommandHelp(c)
+		}
+		task := strings.Join(c.Args().Slice(), " ")
+		return runShell(c.Context, context.GetGlobal(), task, c.String("shell"), c.Bool("force"))
+	},
+}
+{%- endif %}
+
+// runShell asks for a command that does task, shows it and runs it once
+// confirmed
+func runShell(stdctx stdcontext.Context, ctx *context.Context, task, shell string, force bool) error {
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
+	if shell == "" {
+		shell = "sh"
+	}
+
+	client, err := ctx.AI()
+	if err != nil {
+		return err
+	}
+	suggestion, err := client.SuggestCommand(stdctx, task, shell)
+	if err != nil {
+		return err
+	}
+
+	row := map[string]any{
+		"command":     suggestion.Command,
+		"explanation": suggestion.Explanation,
+		"risk":        suggestion.Risk,
+	}
+	if err := ctx.Output.Data([]map[string]any{row}, "Suggested command"); err != nil {
+		return err
+	}
+
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would run: %s", suggestion.Command)
+		return nil
+	}
+
+	// --force never skips the typed confirmation
+	if suggestion.Risk == ai.RiskHigh || suggestion.Destructive() {
+		ctx.Output.Warning("This command is high risk: it may delete data or be hard to undo")
+		answer, err := ctx.Output.Prompt(fmt.Sprintf("Type %q to run it", shellConfirmation))
+		if err != nil || strings.TrimSpace(answer) != shellConfirmation {
+			ctx.Output.Info("Cancelled")
+			return nil
+		}
+	} else if !force && !ctx.Confirm("Run this command?", false) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+
+	return runCommand(stdctx, shell, suggestion.Command)
+}
+
+// runCommand runs command with shell, streaming its output
+func runCommand(stdctx stdcontext.Context, shell, command string) error {
+	cmd := exec.CommandContext(stdctx, shell, "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("command exited with status %d", exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+	return nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,177 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	stdcontext "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+)
+
+// maxFileRead caps how much of a file read_file returns to the model
+const maxFileRead = 64 * 1024
+
+// pathInput is the input of the file tools
+type pathInput struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// fileTools returns tools for working with files under the working
+// directory. Writing files changes state and needs approval.
+func fileTools() []ai.Tool {
+	pathSchema := map[string]any{
+		"type":        "string",
+		"description": "path relative to the working directory",
+	}
+
+	return []ai.Tool{
+		ai.NewReadOnlyTool("list_files",
+			"List the files in a directory under the working directory",
+			map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"path": pathSchema},
+			},
+			listFiles,
+		),
+		ai.NewReadOnlyTool("read_file",
+			"Read a text file under the working directory",
+			map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"path": pathSchema},
+				"required":   []string{"path"},
+			},
+			readFile,
+		),
+		ai.NewMutatingTool("write_file",
+			"Create or overwrite a text file under the working directory",
+			map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"path":    pathSchema,
+					"content": map[string]any{"type": "string", "description": "the complete file content"},
+				},
+				"required": []string{"path", "content"},
+			},
+			writeFile,
+		),
+	}
+}
+
+// localPath decodes a tool's input and checks that its path stays inside
+// the working directory, following symlinks
+func localPath(input json.RawMessage) (pathInput, error) {
+	var in pathInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return in, fmt.Errorf("invalid input: %w", err)
+	}
+	if in.Path == "" {
+		in.Path = "."
+	}
+	if !filepath.IsLocal(in.Path) && filepath.Clean(in.Path) != "." {
+		return in, fmt.Errorf("path %q is outside the working directory", in.Path)
+	}
+	inside, err := insideWorkDir(in.Path)
+	if err != nil {
+		return in, err
+	}
+	if !inside {
+		return in, fmt.Errorf("path %q links outside the working directory", in.Path)
+	}
+	return in, nil
+}
+
+// insideWorkDir reports whether path, with its symlinks resolved, is inside
+// the working directory. A path that does not exist yet, as for write_file,
+// is resolved through its nearest existing parent.
+func insideWorkDir(path string) (bool, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	if wd, err = filepath.EvalSymlinks(wd); err != nil {
+		return false, err
+	}
+
+	dir, rest := filepath.Join(wd, path), ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			rel, err := filepath.Rel(wd, filepath.Join(resolved, rest))
+			if err != nil {
+				return false, err
+			}
+			return rel == "." || filepath.IsLocal(rel), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+		if _, err := os.Lstat(dir); err == nil {
+			// A dangling symlink, which a write would follow
+			return false, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = filepath.Dir(dir)
+	}
+}
+
+func listFiles(_ stdcontext.Context, input json.RawMessage) (string, error) {
+	in, err := localPath(input)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(in.Path)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func readFile(_ stdcontext.Context, input json.RawMessage) (string, error) {
+	in, err := localPath(input)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(in.Path)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxFileRead {
+		return string(data[:maxFileRead]) + "\n[truncated]", nil
+	}
+	return string(data), nil
+}
+
+func writeFile(_ stdcontext.Context, input json.RawMessage) (string, error) {
+	in, err := localPath(input)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(in.Path), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(in.Path, []byte(in.Content), 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(in.Content), in.Path), nil
+}
+{%- else %}
+package ai
+{%- endif %}
@@ -0,0 +1,169 @@
+{%- if values.aiProvider != "none" %}
+package ai
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and estimated cost by day and model",
+	Long: `Show token usage and estimated cost by day and model.
+
+Every AI request is recorded in the local usage ledger. Costs are estimated
+from the prices configured under ai.prices; models without a price show
+"unknown".`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days, _ := cmd.Flags().GetInt("days")
+		return showUsage(context.GetGlobal(), days)
+	},
+}
+
+func init() {
+	usageCmd.Flags().Int("days", 30, "number of days to include")
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var usageCmd = &cli.Command{
+	Name:  "usage",
+	Usage: "Show token usage and estimated cost by day and model",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "days",
+			Value: 30,
+			Usage: "number of days to include",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		return showUsage(context.GetGlobal(), c.Int("days"))
+	},
+}
+{%- endif %}
+
+// usageKey groups ledger records
+type usageKey struct {
+	day      string
+	provider string
+	model    string
+}
+
+// usageTotal accumulates the records of one group
+type usageTotal struct {
+	calls int
+	usage ai.Usage
+}
+
+// showUsage prints the ledger aggregated by local day and model
+func showUsage(ctx *context.Context, days int) error {
+	if days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+
+	ledger, err := ai.DefaultLedger()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, time.Local)
+	records, err := ledger.Records(since)
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[usageKey]*usageTotal)
+	for _, r := range records {
+		key := usageKey{day: r.Time.Local().Format(time.DateOnly), provider: r.Provider, model: r.Model}
+		if totals[key] == nil {
+			totals[key] = &usageTotal{}
+		}
+		totals[key].calls++
+		totals[key].usage.Add(r.Usage)
+	}
+
+	keys := make([]usageKey, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].day != keys[j].day {
+			return keys[i].day < keys[j].day
+		}
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		return keys[i].model < keys[j].model
+	})
+
+	var all usageTotal
+	var cost float64
+	costKnown := true
+	rows := make([]map[string]any, 0, len(keys)+1)
+	for _, key := range keys {
+		t := totals[key]
+		all.calls += t.calls
+		all.usage.Add(t.usage)
+
+		var rowCost any = "unknown"
+		if price, ok := ctx.Config.AI.Price(key.model); ok {
+			c := t.usage.Cost(price)
+			cost += c
+			rowCost = roundCost(c)
+		} else {
+			costKnown = false
+		}
+
+		rows = append(rows, map[string]any{
+			"day":           key.day,
+			"provider":      key.provider,
+			"model":         key.model,
+			"calls":         t.calls,
+			"input_tokens":  t.usage.InputTokens,
+			"output_tokens": t.usage.OutputTokens,
+			"cost_usd":      rowCost,
+		})
+	}
+
+	if len(rows) > 0 {
+		// A partial sum would understate the cost
+		var totalCost any = roundCost(cost)
+		if !costKnown {
+			totalCost = "unknown"
+		}
+		rows = append(rows, map[string]any{
+			"day":           "total",
+			"provider":      "",
+			"model":         "",
+			"calls":         all.calls,
+			"input_tokens":  all.usage.InputTokens,
+			"output_tokens": all.usage.OutputTokens,
+			"cost_usd":      totalCost,
+		})
+	}
+
+	return ctx.Output.Data(rows, fmt.Sprintf("AI usage (last %d days)", days))
+}
+
+// roundCost rounds a cost in USD to a hundredth of a cent
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e4) / 1e4
+}
+{%- else %}
+package ai
+{%- endif %}
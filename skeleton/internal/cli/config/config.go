@@ -0,0 +1,365 @@
+// Package config provides the config commands
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+// Cmd is the root config command
+var Cmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and edit configuration",
+	Long: `Show the configuration, merged from every layer, or edit the config files.
+
+set, unset, edit and init change the user config file (~/.${{values.name}}/config.<ext>),
+the project file (.${{values.name}}.<ext>) with --project, or the file given with --config.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showConfig(context.GetGlobal(), "")
+	},
+}
+
+func init() {
+	Cmd.AddCommand(initCmd)
+	initCmd.Flags().BoolP("force", "f", false, "overwrite an existing file without confirmation")
+	initCmd.Flags().Bool("defaults", false, "write the defaults without asking questions")
+	addProjectFlag(initCmd)
+	Cmd.AddCommand(getCmd)
+	Cmd.AddCommand(setCmd)
+	addProjectFlag(setCmd)
+	Cmd.AddCommand(unsetCmd)
+	addProjectFlag(unsetCmd)
+	Cmd.AddCommand(editCmd)
+	addProjectFlag(editCmd)
+	Cmd.AddCommand(validateCmd)
+	Cmd.AddCommand(pathCmd)
+	Cmd.AddCommand(schemaCmd)
+{%- if values.configFormat == "all" %}
+	Cmd.AddCommand(convertCmd)
+	convertCmd.Flags().String("to", "", convertUsage)
+	convertCmd.Flags().BoolP("force", "f", false, "overwrite an existing file without confirmation")
+	addProjectFlag(convertCmd)
+{%- endif %}
+}
+
+// addProjectFlag adds --project to a command that writes a config file
+func addProjectFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("project", false, "write the project file (.${{values.name}}.<ext>) instead of the user file")
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show the value of a config key or section",
+	Long: `Show the value of a config key, such as logging.level, or of a section, such as logging.
+
+The value is the one in effect, after merging every layer.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var key string
+		if len(args) > 0 {
+			key = args[0]
+		}
+		return showConfig(context.GetGlobal(), key)
+	},
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a config key in a config file",
+	Long: `Set a config key in a config file. Use profiles.<name>.<key> to set it in a profile.
+
+Lists are comma-separated.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		project, _ := cmd.Flags().GetBool("project")
+		return setKey(context.GetGlobal(), project, args[0], args[1])
+	},
+}
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a config key from a config file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		project, _ := cmd.Flags().GetBool("project")
+		return unsetKey(context.GetGlobal(), project, args[0])
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Check config files for errors",
+	Long:  "Check config files for syntax errors, unknown keys and values of the wrong type. Without arguments, checks the files that are read.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return validateFiles(context.GetGlobal(), args)
+	},
+}
+
+var pathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Show the config files that are read, lowest precedence first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return showPaths(context.GetGlobal())
+	},
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print a JSON Schema for config files",
+	Long: `Print a JSON Schema for config files, for editors to complete and check keys with.
+
+Save it next to your config file and point your editor at it, for example with
+a "# yaml-language-server: $schema=schema.json" comment at the top of a YAML file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return context.GetGlobal().Output.JSON(config.Schema())
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+// Cmd is the root config command
+var Cmd = &cli.Command{
+	Name:  "config",
+	Usage: "Show and edit configuration",
+	Description: `Show the configuration, merged from every layer, or edit the config files.
+
+set, unset, edit and init change the user config file (~/.${{values.name}}/config.<ext>),
+the project file (.${{values.name}}.<ext>) with --project, or the file given with --config.`,
+	Action: func(c *cli.Context) error {
+		return showConfig(context.GetGlobal(), "")
+	},
+	Subcommands: []*cli.Command{
+		initCmd,
+		{
+			Name:      "get",
+			Usage:     "Show the value of a config key or section",
+			ArgsUsage: "[key]",
+			Action: func(c *cli.Context) error {
+				return showConfig(context.GetGlobal(), c.Args().First())
+			},
+		},
+		{
+			Name:      "set",
+			Usage:     "Set a config key in a config file",
+			ArgsUsage: "<key> <value>",
+			Flags:     []cli.Flag{projectFlag()},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 2 {
+					return fmt.Errorf("expected a key and a value")
+				}
+				return setKey(context.GetGlobal(), c.Bool("project"), c.Args().Get(0), c.Args().Get(1))
+			},
+		},
+		{
+			Name:      "unset",
+			Usage:     "Remove a config key from a config file",
+			ArgsUsage: "<key>",
+			Flags:     []cli.Flag{projectFlag()},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected a key")
+				}
+				return unsetKey(context.GetGlobal(), c.Bool("project"), c.Args().First())
+			},
+		},
+		editCmd,
+		{
+			Name:      "validate",
+			Usage:     "Check config files for errors",
+			ArgsUsage: "[file...]",
+			Action: func(c *cli.Context) error {
+				return validateFiles(context.GetGlobal(), c.Args().Slice())
+			},
+		},
+		{
+			Name:  "path",
+			Usage: "Show the config files that are read, lowest precedence first",
+			Action: func(c *cli.Context) error {
+				return showPaths(context.GetGlobal())
+			},
+		},
+		{
+			Name:  "schema",
+			Usage: "Print a JSON Schema for config files",
+			Description: `Print a JSON Schema for config files, for editors to complete and check keys with.
+
+Save it next to your config file and point your editor at it, for example with
+a "# yaml-language-server: $schema=schema.json" comment at the top of a YAML file.`,
+			Action: func(c *cli.Context) error {
+				return context.GetGlobal().Output.JSON(config.Schema())
+			},
+		},
+{%- if values.configFormat == "all" %}
+		convertCmd,
+{%- endif %}
+	},
+}
+
+// projectFlag is --project, for the commands that write a config file
+func projectFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "project",
+		Usage: "write the project file (.${{values.name}}.<ext>) instead of the user file",
+	}
+}
+{%- endif %}
+
+// showConfig prints the value of a config key or section; an empty key
+// prints the whole configuration. Single values are printed bare, for
+// scripts. Secrets are shown as their references, or hidden.
+func showConfig(ctx *context.Context, key string) error {
+	if ctx.ConfigErr != nil {
+		return ctx.ConfigErr
+	}
+	value, err := ctx.Config.Redacted().Get(key)
+	if err != nil {
+		return err
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Invalid:
+		fmt.Println()
+		return nil
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		title := "Configuration"
+		if key != "" {
+			title = key
+		}
+		return ctx.Output.Data(value, title)
+	default:
+		fmt.Println(value)
+		return nil
+	}
+}
+
+// setKey sets a config key in the target config file
+func setKey(ctx *context.Context, project bool, key, value string) error {
+	path, err := config.TargetFile(ctx.ConfigFile, project)
+	if err != nil {
+		return err
+	}
+	plain := config.IsSecret(key) && !config.IsReference(value)
+	if ctx.DryRun && plain {
+		ctx.Output.DryRun("Would set %s in %s", key, path)
+		return nil
+	}
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would set %s to %q in %s", key, value, path)
+		return nil
+	}
+	if err := config.Set(path, key, value); err != nil {
+		return err
+	}
+	ctx.Output.Success(fmt.Sprintf("Set %s in %s", key, path))
+	if plain {
+		ctx.Output.Warning(fmt.Sprintf("%s is stored in plain text; store it with `${{values.name}} secrets set <name>` and set %s to secret:<name>, or to env:VAR", key, key))
+	}
+	return nil
+}
+
+// unsetKey removes a config key from the target config file
+func unsetKey(ctx *context.Context, project bool, key string) error {
+	path, err := config.TargetFile(ctx.ConfigFile, project)
+	if err != nil {
+		return err
+	}
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would remove %s from %s", key, path)
+		return nil
+	}
+	removed, err := config.Unset(path, key)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		ctx.Output.Info(fmt.Sprintf("%s is not set in %s", key, path))
+		return nil
+	}
+	ctx.Output.Success(fmt.Sprintf("Removed %s from %s", key, path))
+	return nil
+}
+
+// validateFiles checks the given config files, or the ones that are read,
+// and then that the configuration as a whole loads
+func validateFiles(ctx *context.Context, paths []string) error {
+	checkLoad := len(paths) == 0
+	if checkLoad {
+		sources, err := config.Sources(ctx.ConfigFile)
+		if err != nil {
+			return err
+		}
+		for _, s := range sources {
+			paths = append(paths, s.Path)
+		}
+		if len(paths) == 0 {
+			ctx.Output.Info("No config files found")
+		}
+	}
+
+	var invalid int
+	for _, path := range paths {
+		if err := config.Validate(path); err != nil {
+			invalid++
+			// The errors name the file and their positions in it
+			ctx.Output.Error(err.Error())
+			continue
+		}
+		ctx.Output.Success(fmt.Sprintf("%s is valid", path))
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d config files are invalid", invalid, len(paths))
+	}
+
+	// The files may be valid while a profile or environment variable is not
+	if checkLoad && ctx.ConfigErr != nil {
+		return ctx.ConfigErr
+	}
+	return nil
+}
+
+// showPaths lists the config files that are read
+func showPaths(ctx *context.Context) error {
+	sources, err := config.Sources(ctx.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		path, err := config.TargetFile(ctx.ConfigFile, false)
+		if err != nil {
+			return err
+		}
+		ctx.Output.Info(fmt.Sprintf("No config files found; `${{values.name}} config init` creates %s", path))
+		return nil
+	}
+
+	rows := make([]map[string]any, 0, len(sources))
+	for _, s := range sources {
+		rows = append(rows, map[string]any{"layer": s.Layer, "path": s.Path})
+	}
+	return ctx.Output.Data(rows, "Config files")
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
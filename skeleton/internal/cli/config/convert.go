@@ -0,0 +1,117 @@
+{%- if values.configFormat == "all" %}
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+// convertUsage describes --to
+var convertUsage = "format to convert to: " + strings.Join(config.Formats(), ", ")
+
+{%- if values.cliFramework == "cobra" %}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert --to <format> [file]",
+	Short: "Convert a config file to YAML, TOML or JSON",
+	Long: `Convert a config file to YAML, TOML or JSON. The file is the one given, or the
+file config set changes. The converted file replaces it, with the new
+extension; the original is kept with a .bak extension, as comments are not
+carried over.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var file string
+		if len(args) > 0 {
+			file = args[0]
+		}
+		to, _ := cmd.Flags().GetString("to")
+		project, _ := cmd.Flags().GetBool("project")
+		force, _ := cmd.Flags().GetBool("force")
+		return runConvert(context.GetGlobal(), file, to, project, force)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var convertCmd = &cli.Command{
+	Name:      "convert",
+	Usage:     "Convert a config file to YAML, TOML or JSON",
+	ArgsUsage: "[file]",
+	Description: `Convert a config file to YAML, TOML or JSON. The file is the one given, or the
+file config set changes. The converted file replaces it, with the new
+extension; the original is kept with a .bak extension, as comments are not
+carried over.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "to",
+			Usage: convertUsage,
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "overwrite an existing file without confirmation",
+		},
+		projectFlag(),
+	},
+	Action: func(c *cli.Context) error {
+		return runConvert(context.GetGlobal(), c.Args().First(), c.String("to"), c.Bool("project"), c.Bool("force"))
+	},
+}
+{%- endif %}
+
+// runConvert converts a config file to another format, keeping the
+// original as a backup
+func runConvert(ctx *context.Context, file, to string, project, force bool) error {
+	if to == "" {
+		return fmt.Errorf("--to must be one of %s", strings.Join(config.Formats(), ", "))
+	}
+	path := file
+	if path == "" {
+		var err error
+		if path, err = config.TargetFile(ctx.ConfigFile, project); err != nil {
+			return err
+		}
+	}
+	target, data, err := config.Convert(path, strings.ToLower(to))
+	if err != nil {
+		return err
+	}
+	backup := path + ".bak"
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would write %s and move %s to %s:\n%s", target, path, backup, data)
+		return nil
+	}
+
+	exists, err := fileExists(target)
+	if err != nil {
+		return err
+	}
+	if exists && !force && !ctx.Confirm(fmt.Sprintf("Overwrite %s?", target), false) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+	if err := config.WriteFile(target, data); err != nil {
+		return err
+	}
+	if err := os.Rename(path, backup); err != nil {
+		return fmt.Errorf("failed to keep %s as %s: %w", path, backup, err)
+	}
+	ctx.Output.Success(fmt.Sprintf("Converted %s to %s; the original is kept as %s", path, target, backup))
+	if path == ctx.ConfigFile {
+		ctx.Output.Info(fmt.Sprintf("Pass --config %s from now on", target))
+	}
+	return nil
+}
+{%- else %}
+package config
+{%- endif %}
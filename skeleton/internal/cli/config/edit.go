@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+// defaultEditor is used when neither $VISUAL nor $EDITOR is set
+const defaultEditor = "vi"
+
+{%- if values.cliFramework == "cobra" %}
+
+var editCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open a config file in $EDITOR and validate it",
+	Long: `Open a config file in $VISUAL or $EDITOR, then validate it, offering to edit it
+again until it is valid. A missing file is created from the defaults first.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		project, _ := cmd.Flags().GetBool("project")
+		return runEdit(context.GetGlobal(), project)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var editCmd = &cli.Command{
+	Name:  "edit",
+	Usage: "Open a config file in $EDITOR and validate it",
+	Description: `Open a config file in $VISUAL or $EDITOR, then validate it, offering to edit it
+again until it is valid. A missing file is created from the defaults first.`,
+	Flags: []cli.Flag{projectFlag()},
+	Action: func(c *cli.Context) error {
+		return runEdit(context.GetGlobal(), c.Bool("project"))
+	},
+}
+{%- endif %}
+
+// runEdit opens the target config file in the user's editor until it is
+// valid or the user gives up
+func runEdit(ctx *context.Context, project bool) error {
+	path, err := config.TargetFile(ctx.ConfigFile, project)
+	if err != nil {
+		return err
+	}
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would edit %s", path)
+		return nil
+	}
+
+	exists, err := fileExists(path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		data, err := config.Marshal(config.Defaults(), path)
+		if err != nil {
+			return err
+		}
+		if err := config.WriteFile(path, data); err != nil {
+			return err
+		}
+	}
+
+	for {
+		if err := openEditor(path); err != nil {
+			return err
+		}
+		err := config.Validate(path)
+		if err == nil {
+			ctx.Output.Success(fmt.Sprintf("%s is valid", path))
+			return nil
+		}
+		ctx.Output.Error(err.Error())
+		if !ctx.Output.Confirm("Edit it again?", true) {
+			return fmt.Errorf("%s is invalid", path)
+		}
+	}
+}
+
+// openEditor runs the user's editor on path and waits for it to exit
+func openEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	// The editor may include arguments, such as "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+	return nil
+}
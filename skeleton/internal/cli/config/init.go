@@ -0,0 +1,175 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+
+{%- if values.aiProvider != "none" %}
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+{%- endif %}
+	"github.com/fast-ish/${{values.name}}/internal/config"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+)
+
+// errCancelled is returned when the user closes the input during the wizard
+var errCancelled = errors.New("cancelled")
+
+// logLevels are the values of logging.level
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+{%- if values.cliFramework == "cobra" %}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a config file by answering a few questions",
+	Long: `Create a commented config file by answering a few questions.
+
+The file is the user config file, the project file with --project, or the
+file given with --config. Its type follows its extension.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		project, _ := cmd.Flags().GetBool("project")
+		force, _ := cmd.Flags().GetBool("force")
+		defaults, _ := cmd.Flags().GetBool("defaults")
+		return runInit(context.GetGlobal(), project, force, defaults)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+var initCmd = &cli.Command{
+	Name:  "init",
+	Usage: "Create a config file by answering a few questions",
+	Description: `Create a commented config file by answering a few questions.
+
+The file is the user config file, the project file with --project, or the
+file given with --config. Its type follows its extension.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "overwrite an existing file without confirmation",
+		},
+		&cli.BoolFlag{
+			Name:  "defaults",
+			Usage: "write the defaults without asking questions",
+		},
+		projectFlag(),
+	},
+	Action: func(c *cli.Context) error {
+		return runInit(context.GetGlobal(), c.Bool("project"), c.Bool("force"), c.Bool("defaults"))
+	},
+}
+{%- endif %}
+
+// runInit writes a config file from the defaults and the user's answers
+func runInit(ctx *context.Context, project, force, defaults bool) error {
+	path, err := config.TargetFile(ctx.ConfigFile, project)
+	if err != nil {
+		return err
+	}
+	exists, err := fileExists(path)
+	if err != nil {
+		return err
+	}
+	if exists && !force && !ctx.DryRun {
+		if !ctx.Output.Confirm(fmt.Sprintf("Overwrite %s?", path), false) {
+			ctx.Output.Info("Cancelled")
+			return nil
+		}
+	}
+
+	cfg := config.Defaults()
+	if !defaults {
+		err := askConfig(ctx, cfg)
+		if errors.Is(err, errCancelled) {
+			ctx.Output.Info("Cancelled")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := config.Marshal(cfg, path)
+	if err != nil {
+		return err
+	}
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would write %s:\n%s", path, data)
+		return nil
+	}
+	if err := config.WriteFile(path, data); err != nil {
+		return err
+	}
+	ctx.Output.Success(fmt.Sprintf("Wrote %s", path))
+{%- if values.aiProvider != "none" %}
+	if cfg.AI.Provider == "openai" || cfg.AI.Provider == "anthropic" {
+		ctx.Output.Info(fmt.Sprintf("Store your API key with `${{values.name}} secrets set %s` and run `${{values.name}} config set ai.api_key secret:%s`, or set %s", cfg.AI.Provider, cfg.AI.Provider, config.EnvVar("ai.api_key")))
+	}
+{%- endif %}
+	return nil
+}
+
+// askConfig asks the user for the main settings and stores the answers in cfg
+func askConfig(ctx *context.Context, cfg *config.Config) error {
+	var err error
+{%- if values.aiProvider != "none" %}
+	if cfg.AI.Provider, err = selectValue(ctx, "AI provider", ai.Providers(), cfg.AI.Provider); err != nil {
+		return err
+	}
+	if cfg.AI.Model, err = askValue(ctx, "Model (empty for the provider's default)", ai.DefaultModel(cfg.AI.Provider)); err != nil {
+		return err
+	}
+	if cfg.AI.Model == ai.DefaultModel(cfg.AI.Provider) {
+		cfg.AI.Model = ""
+	}
+	switch cfg.AI.Provider {
+	case "bedrock":
+		if cfg.AI.Region, err = askValue(ctx, "AWS region", cfg.AI.Region); err != nil {
+			return err
+		}
+	case "ollama":
+		if cfg.AI.Host, err = askValue(ctx, "Ollama URL (empty for OLLAMA_HOST or http://localhost:11434)", cfg.AI.Host); err != nil {
+			return err
+		}
+	}
+{%- endif %}
+	cfg.Logging.Level, err = selectValue(ctx, "Log level", logLevels, cfg.Logging.Level)
+	return err
+}
+
+// askValue prompts for a value, which defaults to def
+func askValue(ctx *context.Context, label, def string) (string, error) {
+	if def != "" {
+		label = fmt.Sprintf("%s [%s]", label, def)
+	}
+	value, err := ctx.Output.Prompt(label)
+	if errors.Is(err, io.EOF) {
+		return "", errCancelled
+	}
+	if err != nil {
+		return "", err
+	}
+	if value = strings.TrimSpace(value); value == "" {
+		return def, nil
+	}
+	return value, nil
+}
+
+// selectValue asks the user to choose one of options, which defaults to def
+func selectValue(ctx *context.Context, label string, options []string, def string) (string, error) {
+	value, err := ctx.Output.Select(label, options, def)
+	if errors.Is(err, io.EOF) {
+		return "", errCancelled
+	}
+	return value, err
+}
@@ -0,0 +1,68 @@
+package cli
+
+{%- if values.aiProvider != "none" %}
+
+import (
+	"errors"
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+)
+{%- endif %}
+
+// Exit codes. 2 is left for usage errors, by convention.
+const (
+	ExitError          = 1 // any other failure
+	ExitAIInit         = 3 // the AI client could not be created
+	ExitAuth           = 4 // the AI provider rejected the credentials
+	ExitRateLimited    = 5 // the AI provider kept throttling requests
+	ExitModelNotFound  = 6 // the AI model does not exist
+	ExitContextTooLong = 7 // the input does not fit the model's context window
+)
+
+{%- if values.aiProvider != "none" %}
+
+// exitErrors maps error kinds to exit codes and hints on how to fix them
+var exitErrors = []struct {
+	err  error
+	code int
+	hint string
+}{
+	{ai.ErrAuth, ExitAuth, "check ai.api_key, OPENAI_API_KEY or ANTHROPIC_API_KEY, or your AWS credentials"},
+	{ai.ErrRateLimited, ExitRateLimited, "try again later, or raise ai.max_attempts or lower ai.requests_per_minute"},
+	{ai.ErrModelNotFound, ExitModelNotFound, "run `${{values.name}} ai models` to list the available models"},
+	{ai.ErrContextTooLong, ExitContextTooLong, "shorten the input or pick a model with a larger context window"},
+}
+{%- endif %}
+
+// ExitCode returns the process exit code for an error returned by Execute
+func ExitCode(err error) int {
+{%- if values.aiProvider != "none" %}
+	var initErr *ai.InitError
+	if errors.As(err, &initErr) {
+		return ExitAIInit
+	}
+	for _, e := range exitErrors {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+{%- endif %}
+	return ExitError
+}
+
+// ErrorMessage returns the message to print for an error returned by
+// Execute, with a hint for the errors that have an obvious fix
+func ErrorMessage(err error) string {
+{%- if values.aiProvider != "none" %}
+	var initErr *ai.InitError
+	if errors.As(err, &initErr) {
+		return err.Error() + "\n  check ai.provider and the provider's settings in your config"
+	}
+	for _, e := range exitErrors {
+		if errors.Is(err, e.err) {
+			return err.Error() + "\n  " + e.hint
+		}
+	}
+{%- endif %}
+	return err.Error()
+}
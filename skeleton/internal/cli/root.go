@@ -12,6 +12,8 @@ import (
 	"github.com/urfave/cli/v2"
 {%- endif %}
 
+	configcmd "github.com/fast-ish/${{values.name}}/internal/cli/config"
+	secretscmd "github.com/fast-ish/${{values.name}}/internal/cli/secrets"
 	"github.com/fast-ish/${{values.name}}/internal/config"
 	"github.com/fast-ish/${{values.name}}/internal/context"
 	"github.com/fast-ish/${{values.name}}/internal/logger"
@@ -65,15 +67,28 @@ Features:
   • OpenTelemetry tracing
 {%- endif %}
 `,
+	// main prints errors, with hints
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Arguments parsed; later errors are not usage errors
+		cmd.SilenceUsage = true
+
 		// Initialize configuration
-		cfg, err := config.Load(viper.GetString("config"))
-		if err != nil {
-			return fmt.Errorf("failed to load config: %w", err)
+		configFile := viper.GetString("config")
+		profile, _ := cmd.Flags().GetString("profile")
+		cfg, configErr := config.Load(configFile, profile)
+		if configErr != nil {
+			configErr = fmt.Errorf("failed to load config: %w", configErr)
+			if !runsWithoutConfig(cmd) {
+				return configErr
+			}
+			cfg = config.Defaults()
 		}
 
 		// Initialize global context
 		ctx := context.NewContext(cfg)
+		ctx.ConfigFile = configFile
+		ctx.ConfigErr = configErr
 
 		// Set verbosity
 		verbose, _ := cmd.Flags().GetCount("verbose")
@@ -101,8 +116,9 @@ func Execute() error {
 
 func init() {
 	// Global flags
-	rootCmd.PersistentFlags().StringP("config", "c", "", "config file (default: ~/.{{values.name}}/config.yaml)")
-	rootCmd.PersistentFlags().StringP("output", "o", "auto", "output format: auto, json, yaml, table")
+	rootCmd.PersistentFlags().StringP("config", "c", "", "config file, read instead of the system, user and project files")
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "config profile to apply (default: $"+config.ProfileEnv+")")
+	rootCmd.PersistentFlags().StringP("output", "o", "auto", "output format: auto, json, yaml, table, junit (reports only)")
 	rootCmd.PersistentFlags().CountP("verbose", "v", "verbose output (-v for info, -vv for debug)")
 	rootCmd.PersistentFlags().Bool("dry-run", false, "show what would happen without making changes")
 	rootCmd.PersistentFlags().Bool("no-color", false, "disable colored output")
@@ -123,23 +139,27 @@ func init() {
 		},
 	})
 
-	// Config command
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "config",
-		Short: "Show current configuration",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.GetGlobal()
-			return ctx.Output.Data(ctx.Config, "Configuration")
-		},
-	})
-
 	// Register command modules
 	registerCommands()
 }
 
+// runsWithoutConfig reports whether cmd is config, secrets or one of their
+// subcommands, which run even when the configuration does not load, to
+// help fix it
+func runsWithoutConfig(cmd *cobra.Command) bool {
+	for ; cmd != nil; cmd = cmd.Parent() {
+		if cmd == configcmd.Cmd || cmd == secretscmd.Cmd {
+			return true
+		}
+	}
+	return false
+}
+
 // registerCommands registers all command modules
 // This is where the modular architecture shines - commands are auto-registered
 func registerCommands() {
+	rootCmd.AddCommand(configcmd.Cmd)
+	rootCmd.AddCommand(secretscmd.Cmd)
 {%- if values.aiProvider != "none" %}
 	rootCmd.AddCommand(ai.Cmd)
 {%- endif %}
@@ -160,13 +180,18 @@ func Execute() error {
 			&cli.StringFlag{
 				Name:    "config",
 				Aliases: []string{"c"},
-				Usage:   "config file (default: ~/.{{values.name}}/config.yaml)",
+				Usage:   "config file, read instead of the system, user and project files",
+			},
+			&cli.StringFlag{
+				Name:    "profile",
+				Aliases: []string{"p"},
+				Usage:   "config profile to apply (default: $" + config.ProfileEnv + ")",
 			},
 			&cli.StringFlag{
 				Name:    "output",
 				Aliases: []string{"o"},
 				Value:   "auto",
-				Usage:   "output format: auto, json, yaml, table",
+				Usage:   "output format: auto, json, yaml, table, junit (reports only)",
 			},
 			&cli.IntFlag{
 				Name:    "verbose",
@@ -184,14 +209,23 @@ func Execute() error {
 			},
 		},
 		Before: func(c *cli.Context) error {
-			// Initialize configuration
-			cfg, err := config.Load(c.String("config"))
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+			// Initialize configuration. The config and secrets commands run
+			// even when it does not load, to help fix it.
+			cfg, configErr := config.Load(c.String("config"), c.String("profile"))
+			if configErr != nil {
+				configErr = fmt.Errorf("failed to load config: %w", configErr)
+				switch c.Args().First() {
+				case configcmd.Cmd.Name, secretscmd.Cmd.Name:
+				default:
+					return configErr
+				}
+				cfg = config.Defaults()
 			}
 
 			// Initialize global context
 			ctx := context.NewContext(cfg)
+			ctx.ConfigFile = c.String("config")
+			ctx.ConfigErr = configErr
 			ctx.Verbose = c.Int("verbose")
 			ctx.Output = output.NewFormatter(c.String("output"))
 			ctx.DryRun = c.Bool("dry-run")
@@ -210,14 +244,8 @@ func Execute() error {
 // registerCommands registers all command modules
 func registerCommands() []*cli.Command {
 	commands := []*cli.Command{
-		{
-			Name:  "config",
-			Usage: "Show current configuration",
-			Action: func(c *cli.Context) error {
-				ctx := context.GetGlobal()
-				return ctx.Output.Data(ctx.Config, "Configuration")
-			},
-		},
+		configcmd.Cmd,
+		secretscmd.Cmd,
 	}
 
 {%- if values.aiProvider != "none" %}
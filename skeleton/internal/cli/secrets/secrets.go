@@ -0,0 +1,343 @@
+// Package secrets provides the secrets commands
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+{%- if values.cliFramework == "cobra" %}
+	"github.com/spf13/cobra"
+{%- elif values.cliFramework == "urfave" %}
+	"github.com/urfave/cli/v2"
+{%- endif %}
+	"golang.org/x/term"
+
+	"github.com/fast-ish/${{values.name}}/internal/config"
+	"github.com/fast-ish/${{values.name}}/internal/context"
+	"github.com/fast-ish/${{values.name}}/internal/secrets"
+)
+
+{%- if values.cliFramework == "cobra" %}
+
+// Cmd is the root secrets command
+var Cmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage secrets in an encrypted file",
+	Long: `Manage secrets in an encrypted file (~/.${{values.name}}/secrets.age), for config
+keys to refer to instead of holding them in plain text:
+
+  ${{values.name}} secrets set my-token
+  ${{values.name}} config set <key> secret:my-token
+
+The file is encrypted with age to a key in ~/.${{values.name}}/secrets.key, which is
+created with the first secret. The file is safe to back up or sync, but
+the key sits next to it in plain form: anyone who can read your home
+directory, such as a backup or another program running as you, can decrypt
+every secret. Protect the key with a passphrase, asked for once by each
+command that uses a secret:
+
+  ${{values.name}} secrets passphrase
+
+Scripts give the passphrase in ${{values.name|upper}}_SECRETS_PASSPHRASE.`,
+}
+
+func init() {
+	Cmd.AddCommand(setCmd)
+	Cmd.AddCommand(getCmd)
+	Cmd.AddCommand(rmCmd)
+	rmCmd.Flags().BoolP("force", "f", false, "remove without confirmation")
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(passphraseCmd)
+	passphraseCmd.Flags().Bool("remove", false, "store the key in plain form again")
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret, read from a prompt or standard input",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSecret(context.GetGlobal(), args[0])
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return getSecret(args[0])
+	},
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		return removeSecret(context.GetGlobal(), args[0], force)
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the names of the stored secrets",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listSecrets(context.GetGlobal())
+	},
+}
+
+var passphraseCmd = &cobra.Command{
+	Use:   "passphrase",
+	Short: "Protect the secrets key with a passphrase, or change it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remove, _ := cmd.Flags().GetBool("remove")
+		return setPassphrase(context.GetGlobal(), remove)
+	},
+}
+
+{%- elif values.cliFramework == "urfave" %}
+
+// Cmd is the root secrets command
+var Cmd = &cli.Command{
+	Name:  "secrets",
+	Usage: "Manage secrets in an encrypted file",
+	Description: `Manage secrets in an encrypted file (~/.${{values.name}}/secrets.age), for config
+keys to refer to instead of holding them in plain text:
+
+  ${{values.name}} secrets set my-token
+  ${{values.name}} config set <key> secret:my-token
+
+The file is encrypted with age to a key in ~/.${{values.name}}/secrets.key, which is
+created with the first secret. The file is safe to back up or sync, but
+the key sits next to it in plain form: anyone who can read your home
+directory, such as a backup or another program running as you, can decrypt
+every secret. Protect the key with a passphrase, asked for once by each
+command that uses a secret:
+
+  ${{values.name}} secrets passphrase
+
+Scripts give the passphrase in ${{values.name|upper}}_SECRETS_PASSPHRASE.`,
+	Subcommands: []*cli.Command{
+		{
+			Name:      "set",
+			Usage:     "Store a secret, read from a prompt or standard input",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected a secret name")
+				}
+				return setSecret(context.GetGlobal(), c.Args().First())
+			},
+		},
+		{
+			Name:      "get",
+			Usage:     "Print a secret",
+			ArgsUsage: "<name>",
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected a secret name")
+				}
+				return getSecret(c.Args().First())
+			},
+		},
+		{
+			Name:      "rm",
+			Usage:     "Remove a secret",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:    "force",
+					Aliases: []string{"f"},
+					Usage:   "remove without confirmation",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("expected a secret name")
+				}
+				return removeSecret(context.GetGlobal(), c.Args().First(), c.Bool("force"))
+			},
+		},
+		{
+			Name:  "list",
+			Usage: "List the names of the stored secrets",
+			Action: func(c *cli.Context) error {
+				return listSecrets(context.GetGlobal())
+			},
+		},
+		{
+			Name:  "passphrase",
+			Usage: "Protect the secrets key with a passphrase, or change it",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "remove",
+					Usage: "store the key in plain form again",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return setPassphrase(context.GetGlobal(), c.Bool("remove"))
+			},
+		},
+	},
+}
+{%- endif %}
+
+// setSecret stores a secret, asking for its value. The value is never an
+// argument, which would be kept in the shell's history.
+func setSecret(ctx *context.Context, name string) error {
+	if err := secrets.CheckName(name); err != nil {
+		return err
+	}
+	store, err := config.Secrets()
+	if err != nil {
+		return err
+	}
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would set secret %s in %s", name, store.Path())
+		return nil
+	}
+
+	value, err := ctx.Output.PromptSecret(fmt.Sprintf("Value of %s", name))
+	if errors.Is(err, io.EOF) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("the value of %s is empty", name)
+	}
+	if err := store.Set(name, value); err != nil {
+		return err
+	}
+	ctx.Output.Success(fmt.Sprintf("Set secret %s; refer to it as secret:%s", name, name))
+	return nil
+}
+
+// getSecret prints a secret bare, for scripts
+func getSecret(name string) error {
+	store, err := config.Secrets()
+	if err != nil {
+		return err
+	}
+	value, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// removeSecret removes a secret, once confirmed
+func removeSecret(ctx *context.Context, name string, force bool) error {
+	store, err := config.Secrets()
+	if err != nil {
+		return err
+	}
+	if _, err := store.Get(name); err != nil {
+		return err
+	}
+	if ctx.DryRun {
+		ctx.Output.DryRun("Would remove secret %s from %s", name, store.Path())
+		return nil
+	}
+	if !force && !ctx.Confirm(fmt.Sprintf("Remove secret %s?", name), false) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+
+	if _, err := store.Remove(name); err != nil {
+		return err
+	}
+	ctx.Output.Success(fmt.Sprintf("Removed secret %s", name))
+	return nil
+}
+
+// listSecrets lists the names of the stored secrets, never their values
+func listSecrets(ctx *context.Context) error {
+	store, err := config.Secrets()
+	if err != nil {
+		return err
+	}
+	names, err := store.Names()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		ctx.Output.Info("No secrets stored; add one with `${{values.name}} secrets set <name>`")
+		return nil
+	}
+
+	rows := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, map[string]any{"name": name, "reference": "secret:" + name})
+	}
+	return ctx.Output.Data(rows, "Secrets")
+}
+
+// setPassphrase encrypts the secrets key with a new passphrase, or stores
+// it in plain form again if remove is set
+func setPassphrase(ctx *context.Context, remove bool) error {
+	store, err := config.Secrets()
+	if err != nil {
+		return err
+	}
+	if ctx.DryRun {
+		if remove {
+			ctx.Output.DryRun("Would remove the passphrase of %s", store.KeyPath())
+		} else {
+			ctx.Output.DryRun("Would protect %s with a passphrase", store.KeyPath())
+		}
+		return nil
+	}
+	// The current passphrase is asked for before the new one
+	if err := store.Unlock(); err != nil {
+		return err
+	}
+
+	if remove {
+		if err := store.SetPassphrase(""); err != nil {
+			return err
+		}
+		ctx.Output.Success(fmt.Sprintf("Removed the passphrase of %s", store.KeyPath()))
+		return nil
+	}
+
+	passphrase, err := ctx.Output.PromptSecret("New passphrase")
+	if errors.Is(err, io.EOF) {
+		ctx.Output.Info("Cancelled")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if passphrase == "" {
+		return errors.New("the passphrase is empty; use --remove to remove it")
+	}
+	// Piped input has no typos to catch, and is read to its end already
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		repeat, err := ctx.Output.PromptSecret("Repeat the passphrase")
+		if errors.Is(err, io.EOF) {
+			ctx.Output.Info("Cancelled")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if repeat != passphrase {
+			return errors.New("the passphrases do not match")
+		}
+	}
+
+	if err := store.SetPassphrase(passphrase); err != nil {
+		return err
+	}
+	ctx.Output.Success(fmt.Sprintf("Protected %s with a passphrase", store.KeyPath()))
+	return nil
+}
@@ -2,25 +2,24 @@
 package config
 
 import (
+{%- if values.cliFramework != "cobra" %}
+	"bytes"
+	"encoding/json"
+{%- endif %}
 	"fmt"
 	"os"
 	"path/filepath"
 
 {%- if values.cliFramework == "cobra" %}
+
 	"github.com/spf13/viper"
 {%- endif %}
-{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
-	"gopkg.in/yaml.v3"
-{%- endif %}
-{%- if values.configFormat == "toml" or values.configFormat == "all" %}
-	"github.com/pelletier/go-toml/v2"
-{%- endif %}
 )
 
 // Config represents the application configuration
 type Config struct {
 {%- if values.aiProvider != "none" %}
-	AI AIConfig `json:"ai" yaml:"ai" toml:"ai"`
+	AI      AIConfig      `json:"ai" yaml:"ai" toml:"ai"`
 {%- endif %}
 {%- for integration in values.integrations %}
 	{{integration|title}} {{integration|title}}Config `json:"{{integration}}" yaml:"{{integration}}" toml:"{{integration}}"`
@@ -36,22 +35,81 @@ type Config struct {
 
 {%- if values.aiProvider != "none" %}
 
-// AIConfig holds AI provider configuration
+// AIConfig holds AI provider configuration. Every provider is compiled in;
+// Provider selects one at runtime and the other fields apply where relevant.
 type AIConfig struct {
-	Provider string `json:"provider" yaml:"provider" toml:"provider"`
-{%- if values.aiProvider == "bedrock" %}
-	Region   string `json:"region" yaml:"region" toml:"region"`
-	Model    string `json:"model" yaml:"model" toml:"model"`
-{%- elif values.aiProvider == "openai" %}
-	APIKey   string `json:"api_key" yaml:"api_key" toml:"api_key"`
-	Model    string `json:"model" yaml:"model" toml:"model"`
-{%- elif values.aiProvider == "anthropic" %}
-	APIKey   string `json:"api_key" yaml:"api_key" toml:"api_key"`
-	Model    string `json:"model" yaml:"model" toml:"model"`
-{%- elif values.aiProvider == "ollama" %}
-	Host     string `json:"host" yaml:"host" toml:"host"`
-	Model    string `json:"model" yaml:"model" toml:"model"`
-{%- endif %}
+	Provider string `json:"provider" yaml:"provider" toml:"provider" validate:"required"`
+	Model    string `json:"model" yaml:"model" toml:"model"`                                            // empty selects the provider's default
+	Region   string `json:"region" yaml:"region" toml:"region"`                                         // bedrock
+	APIKey   string `json:"api_key" yaml:"api_key" toml:"api_key" mapstructure:"api_key" secret:"true"` // openai, anthropic
+	Host     string `json:"host" yaml:"host" toml:"host" validate:"url"`                                // ollama
+
+	// EmbeddingModel is used by `ai index` and `ai ask`; empty selects the
+	// provider's default
+	EmbeddingModel string `json:"embedding_model,omitempty" yaml:"embedding_model,omitempty" toml:"embedding_model,omitempty" mapstructure:"embedding_model"`
+
+	// Generation defaults; unset values leave the provider's defaults
+	MaxTokens   int      `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty" toml:"max_tokens,omitempty" mapstructure:"max_tokens" validate:"min=0"`
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty" toml:"temperature,omitempty" validate:"min=0,max=2"`
+	TopP        *float64 `json:"top_p,omitempty" yaml:"top_p,omitempty" toml:"top_p,omitempty" mapstructure:"top_p" validate:"min=0,max=1"`
+	Stop        []string `json:"stop,omitempty" yaml:"stop,omitempty" toml:"stop,omitempty"`
+	System      string   `json:"system,omitempty" yaml:"system,omitempty" toml:"system,omitempty"`
+
+	// Context management; zero values select the defaults
+	ContextWindow int `json:"context_window,omitempty" yaml:"context_window,omitempty" toml:"context_window,omitempty" mapstructure:"context_window" validate:"min=0"` // tokens; default the model's known window
+	KeepTurns     int `json:"keep_turns,omitempty" yaml:"keep_turns,omitempty" toml:"keep_turns,omitempty" mapstructure:"keep_turns" validate:"min=0"`                 // recent turns kept verbatim when compacting; default 4
+
+	// Resilience; zero values select the defaults
+	MaxAttempts       int `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty" toml:"max_attempts,omitempty" mapstructure:"max_attempts" validate:"min=0"`                             // per call, including retries
+	Timeout           int `json:"timeout,omitempty" yaml:"timeout,omitempty" toml:"timeout,omitempty" validate:"min=0"`                                                                        // seconds per call, including retries; 0 disables
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" yaml:"requests_per_minute,omitempty" toml:"requests_per_minute,omitempty" mapstructure:"requests_per_minute" validate:"min=0"` // 0 disables
+
+	// Prices are used to estimate cost in `ai usage`
+	Prices []ModelPrice `json:"prices,omitempty" yaml:"prices,omitempty" toml:"prices,omitempty"`
+
+	Cache CacheConfig `json:"cache" yaml:"cache" toml:"cache"`
+
+	// Fixtures is the file the fake provider replays; Record, if set, is a
+	// fixtures file every reply from a real provider is appended to. In a
+	// config file, both are relative to the file.
+	Fixtures string `json:"fixtures,omitempty" yaml:"fixtures,omitempty" toml:"fixtures,omitempty" path:"true"`
+	Record   string `json:"record,omitempty" yaml:"record,omitempty" toml:"record,omitempty" path:"true"`
+
+	// Prompts add to and override the built-in prompt templates
+	Prompts []PromptConfig `json:"prompts,omitempty" yaml:"prompts,omitempty" toml:"prompts,omitempty"`
+}
+
+// PromptConfig defines a prompt template in config
+type PromptConfig struct {
+	Name        string `json:"name" yaml:"name" toml:"name" validate:"required"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
+	Template    string `json:"template" yaml:"template" toml:"template" validate:"required"` // text/template; the main input is .Text
+}
+
+// CacheConfig controls the on-disk AI response cache; zero values select
+// the defaults
+type CacheConfig struct {
+	Enabled   bool `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Only      bool `json:"only,omitempty" yaml:"only,omitempty" toml:"only,omitempty"`                                                                  // fail on a cache miss instead of calling the provider
+	TTLHours  int  `json:"ttl_hours,omitempty" yaml:"ttl_hours,omitempty" toml:"ttl_hours,omitempty" mapstructure:"ttl_hours" validate:"min=0"`         // default 168
+	MaxSizeMB int  `json:"max_size_mb,omitempty" yaml:"max_size_mb,omitempty" toml:"max_size_mb,omitempty" mapstructure:"max_size_mb" validate:"min=0"` // default 100
+}
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Model  string  `json:"model" yaml:"model" toml:"model" validate:"required"`
+	Input  float64 `json:"input" yaml:"input" toml:"input" validate:"min=0"`
+	Output float64 `json:"output" yaml:"output" toml:"output" validate:"min=0"`
+}
+
+// Price returns the configured price of a model
+func (c AIConfig) Price(model string) (ModelPrice, bool) {
+	for _, p := range c.Prices {
+		if p.Model == model {
+			return p, true
+		}
+	}
+	return ModelPrice{}, false
 }
 {%- endif %}
 
@@ -63,33 +121,33 @@ type {{integration|title}}Config struct {
 	Region  string `json:"region" yaml:"region" toml:"region"`
 	Profile string `json:"profile" yaml:"profile" toml:"profile"`
 {%- elif integration == "github" %}
-	Token string `json:"token" yaml:"token" toml:"token"`
+	Token string `json:"token" yaml:"token" toml:"token" secret:"true"`
 	Org   string `json:"org" yaml:"org" toml:"org"`
 {%- elif integration == "kubernetes" %}
 	Kubeconfig string `json:"kubeconfig" yaml:"kubeconfig" toml:"kubeconfig"`
 	Context    string `json:"context" yaml:"context" toml:"context"`
 {%- elif integration == "grafana" %}
-	URL   string `json:"url" yaml:"url" toml:"url"`
-	Token string `json:"token" yaml:"token" toml:"token"`
+	URL   string `json:"url" yaml:"url" toml:"url" validate:"url"`
+	Token string `json:"token" yaml:"token" toml:"token" secret:"true"`
 {%- elif integration == "slack" %}
-	Token   string `json:"token" yaml:"token" toml:"token"`
+	Token   string `json:"token" yaml:"token" toml:"token" secret:"true"`
 	Channel string `json:"channel" yaml:"channel" toml:"channel"`
 {%- elif integration == "notion" %}
-	Token string `json:"token" yaml:"token" toml:"token"`
+	Token string `json:"token" yaml:"token" toml:"token" secret:"true"`
 {%- elif integration == "argocd" %}
-	URL      string `json:"url" yaml:"url" toml:"url"`
-	Token    string `json:"token" yaml:"token" toml:"token"`
+	URL      string `json:"url" yaml:"url" toml:"url" validate:"url"`
+	Token    string `json:"token" yaml:"token" toml:"token" secret:"true"`
 	Insecure bool   `json:"insecure" yaml:"insecure" toml:"insecure"`
 {%- elif integration == "terraform" %}
-	WorkingDir string `json:"working_dir" yaml:"working_dir" toml:"working_dir"`
+	WorkingDir string `json:"working_dir" yaml:"working_dir" toml:"working_dir" mapstructure:"working_dir"`
 {%- endif %}
 }
 {%- endfor %}
 
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
-	Level  string `json:"level" yaml:"level" toml:"level"`
-	Format string `json:"format" yaml:"format" toml:"format"` // json or text
+	Level  string `json:"level" yaml:"level" toml:"level" validate:"oneof=debug info warn error"`
+	Format string `json:"format" yaml:"format" toml:"format" validate:"oneof=json text"` // json or text
 }
 
 {%- if values.metrics %}
@@ -97,7 +155,7 @@ type LoggingConfig struct {
 // MetricsConfig holds metrics configuration
 type MetricsConfig struct {
 	Enabled bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
-	Port    int    `json:"port" yaml:"port" toml:"port"`
+	Port    int    `json:"port" yaml:"port" toml:"port" validate:"port"`
 	Path    string `json:"path" yaml:"path" toml:"path"`
 }
 {%- endif %}
@@ -107,94 +165,78 @@ type MetricsConfig struct {
 // TracingConfig holds tracing configuration
 type TracingConfig struct {
 	Enabled  bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
-	Endpoint string `json:"endpoint" yaml:"endpoint" toml:"endpoint"`
+	Endpoint string `json:"endpoint" yaml:"endpoint" toml:"endpoint" validate:"url"`
 	Service  string `json:"service" yaml:"service" toml:"service"`
 }
 {%- endif %}
 
-// Load loads configuration from file
-func Load(configFile string) (*Config, error) {
-{%- if values.cliFramework == "cobra" %}
-	v := viper.New()
-
-	// Set defaults
-	setDefaults(v)
-
-	// Config file paths to try
-	if configFile != "" {
-		v.SetConfigFile(configFile)
-	} else {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-
-		v.SetConfigName("config")
-{%- if values.configFormat == "yaml" %}
-		v.SetConfigType("yaml")
-{%- elif values.configFormat == "toml" %}
-		v.SetConfigType("toml")
-{%- elif values.configFormat == "json" %}
-		v.SetConfigType("json")
-{%- elif values.configFormat == "all" %}
-		v.SetConfigType("yaml") // default
-{%- endif %}
-		v.AddConfigPath(filepath.Join(home, ".${{values.name}}"))
-		v.AddConfigPath(".")
+// Dir returns the per-user directory holding the config file and other
+// application state (~/.${{values.name}})
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return filepath.Join(home, ".${{values.name}}"), nil
+}
 
-	// Environment variables
-	v.SetEnvPrefix("{{values.name|upper}}")
-	v.AutomaticEnv()
+// Load loads the layered configuration: defaults, then the system, user and
+// project config files, then the settings of the named profile from those
+// files, then environment variables. profile defaults to the
+// ${{values.name|upper}}_PROFILE environment variable. Secret references, such as
+// env:VAR, are kept for Resolve. Commands apply their flags on top.
+func Load(configFile, profile string) (*Config, error) {
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	values, err := loadLayers(configFile, profile)
+	if err != nil {
+		return nil, err
+	}
 
-	// Read config
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return nil, fmt.Errorf("failed to read config: %w", err)
-		}
-		// Config file not found, use defaults
+{%- if values.cliFramework == "cobra" %}
+	v := viper.New()
+	setDefaults(v)
+	if err := v.MergeConfigMap(values); err != nil {
+		return nil, fmt.Errorf("failed to merge config: %w", err)
 	}
 
-	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	cfg := &Config{}
+	if err := v.UnmarshalExact(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-
-	return &cfg, nil
 {%- else %}
-	// Simple config loading without viper
-	if configFile == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
-		}
-		configFile = filepath.Join(home, ".${{values.name}}", "config.yaml")
-	}
-
-	data, err := os.ReadFile(configFile)
+	// Decode through JSON, whose tags match the config keys
+	data, err := json.Marshal(values)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Use defaults
-			return defaultConfig(), nil
-		}
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to merge config: %w", err)
 	}
-
-	var cfg Config
-{%- if values.configFormat == "yaml" %}
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-{%- elif values.configFormat == "toml" %}
-	if err := toml.Unmarshal(data, &cfg); err != nil {
-{%- elif values.configFormat == "json" %}
-	if err := json.Unmarshal(data, &cfg); err != nil {
-{%- elif values.configFormat == "all" %}
-	// Try YAML first
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-{%- endif %}
+	cfg := defaultConfig()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+{%- endif %}
+
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
-	return &cfg, nil
+// Defaults returns the configuration used when no file or environment
+// variable sets a key
+func Defaults() *Config {
+{%- if values.cliFramework == "cobra" %}
+	v := viper.New()
+	setDefaults(v)
+	var cfg Config
+	// The defaults always decode
+	_ = v.Unmarshal(&cfg)
+	return &cfg
+{%- else %}
+	return defaultConfig()
 {%- endif %}
 }
 
@@ -216,17 +258,7 @@ func setDefaults(v *viper.Viper) {
 {%- endif %}
 {%- if values.aiProvider != "none" %}
 	v.SetDefault("ai.provider", "${{values.aiProvider}}")
-{%- if values.aiProvider == "bedrock" %}
 	v.SetDefault("ai.region", "us-west-2")
-	v.SetDefault("ai.model", "anthropic.claude-3-sonnet-20240229-v1:0")
-{%- elif values.aiProvider == "openai" %}
-	v.SetDefault("ai.model", "gpt-4")
-{%- elif values.aiProvider == "anthropic" %}
-	v.SetDefault("ai.model", "claude-3-5-sonnet-20241022")
-{%- elif values.aiProvider == "ollama" %}
-	v.SetDefault("ai.host", "http://localhost:11434")
-	v.SetDefault("ai.model", "llama2")
-{%- endif %}
 {%- endif %}
 }
 {%- else %}
@@ -255,17 +287,7 @@ func defaultConfig() *Config {
 {%- if values.aiProvider != "none" %}
 		AI: AIConfig{
 			Provider: "${{values.aiProvider}}",
-{%- if values.aiProvider == "bedrock" %}
 			Region:   "us-west-2",
-			Model:    "anthropic.claude-3-sonnet-20240229-v1:0",
-{%- elif values.aiProvider == "openai" %}
-			Model:    "gpt-4",
-{%- elif values.aiProvider == "anthropic" %}
-			Model:    "claude-3-5-sonnet-20241022",
-{%- elif values.aiProvider == "ollama" %}
-			Host:     "http://localhost:11434",
-			Model:    "llama2",
-{%- endif %}
 		},
 {%- endif %}
 	}
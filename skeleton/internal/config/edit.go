@@ -0,0 +1,388 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+	"gopkg.in/yaml.v3"
+{%- endif %}
+)
+
+// Keys returns the dotted names of the config keys that can be set from
+// the command line or the environment, sorted
+func Keys() []string {
+	var keys []string
+	walkKeys(reflect.TypeOf(Config{}), nil, func(path []string, t reflect.Type) {
+		keys = append(keys, strings.Join(path, "."))
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// EnvVar returns the environment variable that overrides a config key,
+// e.g. ${{values.name|upper}}_AI_MODEL for ai.model
+func EnvVar(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Get returns the value of a config key or section, e.g. "ai.model" or
+// "ai". An empty key returns the whole configuration.
+func (c *Config) Get(key string) (any, error) {
+	v := reflect.ValueOf(c).Elem()
+	if key == "" {
+		return v.Interface(), nil
+	}
+	for _, name := range strings.Split(strings.ToLower(key), ".") {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("unknown config key %q", key)
+		}
+		field, ok := fieldByKey(v.Type(), name)
+		if !ok {
+			return nil, fmt.Errorf("unknown config key %q", key)
+		}
+		v = v.FieldByIndex(field.Index)
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), nil
+}
+
+// fieldByKey returns the field of struct type t whose config key is key
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == key && field.IsExported() {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// settableKey splits a dotted key into its path and returns the type of its
+// value. Keys under profiles.<name> set the key in that profile.
+func settableKey(key string) ([]string, reflect.Type, error) {
+	path := strings.Split(strings.ToLower(key), ".")
+	name := path
+	if path[0] == profilesKey {
+		if len(path) < 3 {
+			return nil, nil, fmt.Errorf("invalid config key %q: expected %s.<profile>.<key>", key, profilesKey)
+		}
+		name = path[2:]
+	}
+
+	var keyType reflect.Type
+	walkKeys(reflect.TypeOf(Config{}), nil, func(p []string, t reflect.Type) {
+		if strings.Join(p, ".") == strings.Join(name, ".") {
+			keyType = t
+		}
+	})
+	if keyType == nil {
+		if _, err := (&Config{}).Get(strings.Join(name, ".")); err == nil {
+			return nil, nil, fmt.Errorf("%s cannot be set from the command line; edit the file with `${{values.name}} config edit`", key)
+		}
+		return nil, nil, fmt.Errorf("unknown config key %q", key)
+	}
+	return path, keyType, nil
+}
+
+// TargetFile returns the config file that `config set` and the other
+// editing commands change: configFile if it is set, otherwise the project
+// file if project is set, otherwise the user file. An existing file is
+// preferred; otherwise the path for a new one is returned.
+func TargetFile(configFile string, project bool) (string, error) {
+	if configFile != "" {
+		return configFile, nil
+	}
+	if project {
+		path, err := findProject()
+		if err != nil || path != "" {
+			return path, err
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get working directory: %w", err)
+		}
+		return filepath.Join(dir, ".${{values.name}}"+extensions[0]), nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	if path := find(dir, "config"); path != "" {
+		return path, nil
+	}
+	return filepath.Join(dir, "config"+extensions[0]), nil
+}
+
+// Set sets a config key in the file at path, which is created if it does
+// not exist. raw is parsed as the key's type, as environment variables are,
+// and must pass validation.
+func Set(path, key, raw string) error {
+	keyPath, t, err := settableKey(key)
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(raw, t)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	_, err = update(path, keyPath, value, false)
+	return err
+}
+
+// Unset removes a config key from the file at path, and reports whether it
+// was set there
+func Unset(path, key string) (bool, error) {
+	keyPath, _, err := settableKey(key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return update(path, keyPath, nil, true)
+}
+
+// update sets or removes the key at keyPath in the file at path, and
+// reports whether the file changed. The file is validated as it would be
+// and left unchanged if it would not load.
+func update(path string, keyPath []string, value any, remove bool) (bool, error) {
+	format := fileFormat(path)
+	var data []byte
+	var changed bool
+	var err error
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	if format == formatYAML {
+		data, changed, err = editYAML(path, keyPath, value, remove)
+	} else {
+		data, changed, err = editValues(path, format, keyPath, value, remove)
+	}
+{%- else %}
+	data, changed, err = editValues(path, format, keyPath, value, remove)
+{%- endif %}
+	if err != nil || !changed {
+		return false, err
+	}
+	if err := checkEdit(path, format, data); err != nil {
+		return false, err
+	}
+	return true, WriteFile(path, data)
+}
+
+// editValues returns the content of the file at path with the key at
+// keyPath set or removed, and whether it changed
+func editValues(path, format string, keyPath []string, value any, remove bool) ([]byte, bool, error) {
+	values := map[string]any{}
+	if _, err := os.Stat(path); err == nil {
+		if values, err = readFile(path); err != nil {
+			return nil, false, err
+		}
+	}
+	if remove {
+		if !unset(values, keyPath) {
+			return nil, false, nil
+		}
+	} else {
+		set(values, keyPath, value)
+	}
+
+	data, err := encodeValues(format, values)
+	return data, err == nil, err
+}
+
+// checkEdit validates data, the content an edit would give the config file
+// at path, so that a bad value cannot make every later load fail. Problems
+// the file already had are left to config validate, so that they do not
+// stand in the way of fixing another key.
+func checkEdit(path, format string, data []byte) error {
+	values, err := decodeValues(format, data)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	known := map[string]bool{}
+	if current, err := readFile(path); err == nil {
+		for _, e := range checkFile(current) {
+			known[e.Error()] = true
+		}
+	}
+
+	var errs []*FieldError
+	for _, e := range checkFile(values) {
+		if known[e.Error()] {
+			continue
+		}
+		e.Source = path
+		errs = append(errs, e)
+	}
+	return joinFieldErrors(errs)
+}
+
+// unset removes the value at path from values, and then any maps left
+// empty, and reports whether it was there
+func unset(values map[string]any, path []string) bool {
+	if len(path) == 1 {
+		_, ok := values[path[0]]
+		delete(values, path[0])
+		return ok
+	}
+	next, ok := values[path[0]].(map[string]any)
+	if !ok || !unset(next, path[1:]) {
+		return false
+	}
+	if len(next) == 0 {
+		delete(values, path[0])
+	}
+	return true
+}
+
+// WriteFile replaces the config file at path with data, creating its
+// directory if needed. New files are readable only by the user, as they
+// may hold API keys.
+func WriteFile(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// Write to a temporary file and rename, so a failed write cannot
+	// truncate the config
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, mode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+// editYAML edits a YAML config file through its node tree, which keeps
+// its comments and key order, and returns its new content and whether it
+// changed
+func editYAML(path string, keyPath []string, value any, remove bool) ([]byte, bool, error) {
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, false, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		// An empty or new file
+		root := &yaml.Node{Kind: yaml.MappingNode}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false, fmt.Errorf("invalid config file %s: expected a mapping of keys to values", path)
+	}
+
+	if remove {
+		if !unsetNode(root, keyPath) {
+			return nil, false, nil
+		}
+	} else {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return nil, false, err
+		}
+		setNode(root, keyPath, &node)
+	}
+
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, false, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, false, err
+	}
+	return []byte(b.String()), true, nil
+}
+
+// mappingIndex returns the index of key's key node in a mapping node, or -1.
+// Keys match case-insensitively, as they are read.
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// setNode stores value at path below a mapping node, creating mappings as
+// needed. A replaced value keeps its comments.
+func setNode(mapping *yaml.Node, path []string, value *yaml.Node) {
+	for _, key := range path[:len(path)-1] {
+		i := mappingIndex(mapping, key)
+		if i < 0 || mapping.Content[i+1].Kind != yaml.MappingNode {
+			child := &yaml.Node{Kind: yaml.MappingNode}
+			if i < 0 {
+				keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+				mapping.Content = append(mapping.Content, keyNode, child)
+			} else {
+				mapping.Content[i+1] = child
+			}
+			mapping = child
+			continue
+		}
+		mapping = mapping.Content[i+1]
+	}
+
+	key := path[len(path)-1]
+	if i := mappingIndex(mapping, key); i >= 0 {
+		old := mapping.Content[i+1]
+		value.HeadComment, value.LineComment, value.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		mapping.Content[i+1] = value
+		return
+	}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+	mapping.Content = append(mapping.Content, keyNode, value)
+}
+
+// unsetNode removes the value at path below a mapping node, and then any
+// mappings left empty, and reports whether it was there
+func unsetNode(mapping *yaml.Node, path []string) bool {
+	i := mappingIndex(mapping, path[0])
+	if i < 0 {
+		return false
+	}
+	if len(path) > 1 {
+		child := mapping.Content[i+1]
+		if child.Kind != yaml.MappingNode || !unsetNode(child, path[1:]) {
+			return false
+		}
+		if len(child.Content) > 0 {
+			return true
+		}
+	}
+	mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+	return true
+}
+{%- endif %}
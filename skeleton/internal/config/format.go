@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	"regexp"
+{%- endif %}
+	"strings"
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+	"gopkg.in/yaml.v3"
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+
+	"github.com/pelletier/go-toml/v2"
+{%- endif %}
+)
+
+// Config file formats
+const (
+	formatYAML = "yaml"
+	formatTOML = "toml"
+	formatJSON = "json"
+)
+
+// formats are the config file formats this build reads, preferred first
+var formats = []string{
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	formatYAML,
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	formatTOML,
+{%- endif %}
+{%- if values.configFormat == "json" or values.configFormat == "all" %}
+	formatJSON,
+{%- endif %}
+}
+
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+
+// tomlLine matches a line that starts a TOML document: a table header or a
+// key = value pair. YAML keys are followed by a colon instead.
+var tomlLine = regexp.MustCompile(`^(\[\[?[A-Za-z0-9_."' -]+\]\]?\s*(#.*)?$|[A-Za-z0-9_."'-]+\s*=)`)
+{%- endif %}
+
+// Formats returns the config file formats this build reads, preferred
+// first
+func Formats() []string {
+	return append([]string(nil), formats...)
+}
+
+// formatOf returns the format of a config file from its extension or,
+// for other names such as a --config file without one, from its content
+func formatOf(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	case ".yaml", ".yml":
+		return formatYAML
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	case ".toml":
+		return formatTOML
+{%- endif %}
+	case ".json":
+		return formatJSON
+	}
+	return sniffFormat(data)
+}
+
+// fileFormat returns the format of the config file at path, which need not
+// exist
+func fileFormat(path string) string {
+	// A missing file is sniffed as empty, which gives the preferred format
+	data, _ := os.ReadFile(path)
+	return formatOf(path, data)
+}
+
+// sniffFormat returns the format of a config file from its first line that
+// is not blank or a comment, or the preferred format if it is not clear
+func sniffFormat(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			return formatJSON
+		}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+		if tomlLine.MatchString(line) {
+			return formatTOML
+		}
+{%- endif %}
+		break
+	}
+	return formats[0]
+}
+
+// decodeValues decodes a config file in format into a map
+func decodeValues(format string, data []byte) (map[string]any, error) {
+	values := map[string]any{}
+	var err error
+	switch format {
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	case formatYAML:
+		err = yaml.Unmarshal(data, &values)
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	case formatTOML:
+		err = toml.Unmarshal(data, &values)
+{%- endif %}
+	default:
+		err = json.Unmarshal(data, &values)
+	}
+	if values == nil {
+		// An empty YAML file
+		values = map[string]any{}
+	}
+	return values, err
+}
+
+// encodeValues encodes values as a config file in format
+func encodeValues(format string, values map[string]any) ([]byte, error) {
+	switch format {
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	case formatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(values); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), enc.Close()
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	case formatTOML:
+		return toml.Marshal(values)
+{%- endif %}
+	default:
+		data, err := json.MarshalIndent(values, "", "  ")
+		return append(data, '\n'), err
+	}
+}
+
+// Convert reads the config file at path and encodes its keys in another
+// format. It returns the path for the converted file, which is path with
+// the format's extension, and its content. Comments are not carried over.
+func Convert(path, format string) (string, []byte, error) {
+	if !contains(formats, format) {
+		return "", nil, fmt.Errorf("unsupported format %q: expected %s", format, orList(formats))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if from := formatOf(path, data); from == format {
+		return "", nil, fmt.Errorf("%s is already %s", path, strings.ToUpper(format))
+	}
+
+	values, err := readFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := validateFile(path, values); err != nil {
+		return "", nil, err
+	}
+	normalize(values, reflect.TypeOf(Config{}))
+	if profiles, ok := values[profilesKey].(map[string]any); ok {
+		for _, profile := range profiles {
+			if section, ok := profile.(map[string]any); ok {
+				normalize(section, reflect.TypeOf(Config{}))
+			}
+		}
+	}
+
+	out, err := encodeValues(format, values)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if format != formatJSON {
+		out = append([]byte("# "+fileHeader+"\n"), out...)
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + format, out, nil
+}
+
+// normalize prepares the decoded values of a section of struct type t for
+// another format: whole numbers decoded as floats, as JSON decodes them,
+// become integers again where t holds integers, whole numbers become floats
+// where t holds floats, and empty values are dropped, as TOML has no null
+func normalize(values map[string]any, t reflect.Type) {
+	for key, value := range values {
+		if value == nil {
+			delete(values, key)
+			continue
+		}
+		field, ok := fieldByKey(t, key)
+		if !ok {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			if ft.Kind() == reflect.Struct {
+				normalize(v, ft)
+			}
+		case []any:
+			if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct {
+				for _, item := range v {
+					if table, ok := item.(map[string]any); ok {
+						normalize(table, ft.Elem())
+					}
+				}
+			}
+		case float64:
+			if ft.Kind() == reflect.Int || ft.Kind() == reflect.Int64 {
+				values[key] = int64(v)
+			}
+		case int:
+			if ft.Kind() == reflect.Float64 {
+				values[key] = float64(v)
+			}
+		case int64:
+			if ft.Kind() == reflect.Float64 {
+				values[key] = float64(v)
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+{%- if values.configFormat == "all" %}
+	"reflect"
+	"strings"
+{%- endif %}
+	"testing"
+)
+
+{%- if values.configFormat == "all" %}
+
+func TestConvertRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		profiles []string
+	}{
+		{
+			name: "scalars",
+			yaml: `logging:
+  level: warn
+  format: json
+`,
+		},
+{%- if values.aiProvider != "none" %}
+		{
+			name: "ints and floats",
+			yaml: `ai:
+  provider: fake
+  max_tokens: 1024
+  temperature: 0.7
+  top_p: 0.95
+  context_window: 200000
+  requests_per_minute: 60
+`,
+		},
+		{
+			name: "lists",
+			yaml: `ai:
+  provider: fake
+  stop:
+    - END
+    - "---"
+  prices:
+    - model: small
+      input: 0.25
+      output: 1.25
+    - model: large
+      input: 3
+      output: 15
+`,
+		},
+		{
+			name: "nested tables",
+			yaml: `ai:
+  provider: fake
+  cache:
+    enabled: true
+    ttl_hours: 24
+    max_size_mb: 50
+  prompts:
+    - name: haiku
+      description: A poem
+      template: Write a haiku about the input
+`,
+		},
+		{
+			name: "profiles",
+			yaml: `ai:
+  provider: fake
+  max_tokens: 512
+logging:
+  level: info
+profiles:
+  dev:
+    ai:
+      max_tokens: 4096
+      temperature: 0.2
+      stop:
+        - STOP
+    logging:
+      level: debug
+  ci:
+    ai:
+      cache:
+        enabled: true
+        only: true
+`,
+			profiles: []string{"dev", "ci"},
+		},
+{%- else %}
+		{
+			name: "profiles",
+			yaml: `logging:
+  level: info
+profiles:
+  dev:
+    logging:
+      level: debug
+      format: json
+`,
+			profiles: []string{"dev"},
+		},
+{%- endif %}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			want := loadProfiles(t, path, tt.profiles)
+
+			// YAML to TOML to JSON and back to YAML, loading each file the
+			// same as the original
+			converted := map[string][]byte{}
+			for _, format := range []string{formatTOML, formatJSON, formatYAML} {
+				next, data, err := Convert(path, format)
+				if err != nil {
+					t.Fatalf("convert %s to %s: %v", filepath.Base(path), format, err)
+				}
+				if err := os.WriteFile(next, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if got := loadProfiles(t, next, tt.profiles); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s loads as\n%+v\nwant\n%+v\nfile:\n%s", format, got, want, data)
+				}
+				converted[format] = data
+				path = next
+			}
+
+			// Integers stay integers and floats stay floats
+			sameValues(t, formatYAML, converted[formatYAML], []byte(tt.yaml))
+			// JSON numbers are all floats, so TOML converted from JSON must get
+			// its integers back from the config types
+			_, data, err := Convert(strings.TrimSuffix(path, ".yaml")+".json", formatTOML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameValues(t, formatTOML, data, converted[formatTOML])
+		})
+	}
+}
+
+// sameValues fails the test if two config files in format decode to
+// different values
+func sameValues(t *testing.T, format string, got, want []byte) {
+	t.Helper()
+	gotValues, err := decodeValues(format, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValues, err := decodeValues(format, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValues, wantValues) {
+		t.Errorf("got %s:\n%s\nwant:\n%s", strings.ToUpper(format), got, want)
+	}
+}
+
+// loadProfiles loads the config file at path alone, then with each profile
+func loadProfiles(t *testing.T, path string, profiles []string) []*Config {
+	t.Helper()
+	var configs []*Config
+	for _, profile := range append([]string{""}, profiles...) {
+		cfg, err := Load(path, profile)
+		if err != nil {
+			t.Fatalf("load %s with profile %q: %v", filepath.Base(path), profile, err)
+		}
+		configs = append(configs, cfg)
+	}
+	return configs
+}
+
+func TestConvertSameFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("[logging]\nlevel = \"info\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Convert(path, formatTOML); err == nil {
+		t.Error("converting TOML to TOML succeeded")
+	}
+	next, _, err := Convert(path, formatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(next) != "config.yaml" {
+		t.Errorf("converted path = %s, want config.yaml", next)
+	}
+}
+{%- endif %}
+
+func TestFormatOfExtension(t *testing.T) {
+	// The extension wins over content that looks like another format
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"config.json", formatJSON},
+		{"CONFIG.JSON", formatJSON},
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+		{"config.yaml", formatYAML},
+		{"config.yml", formatYAML},
+		{"Config.YAML", formatYAML},
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+		{"config.toml", formatTOML},
+		{"dir/config.Toml", formatTOML},
+{%- endif %}
+	}
+	for _, tt := range tests {
+		for _, data := range []string{"", "{}", "[logging]\n", "logging:\n  level: info\n"} {
+			if got := formatOf(tt.path, []byte(data)); got != tt.want {
+				t.Errorf("formatOf(%q, %q) = %s, want %s", tt.path, data, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", formats[0]},
+		{"comments only", "# nothing yet\n\n", formats[0]},
+		{"json", "{\n  \"logging\": {\"level\": \"info\"}\n}\n", formatJSON},
+		{"json after blank lines", "\n\n  {}", formatJSON},
+		{"json with byte order mark", "\xef\xbb\xbf{}", formatJSON},
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+		{"yaml", "logging:\n  level: info\n", formatYAML},
+		{"yaml after a comment", "# config\nlogging:\n  format: text\n", formatYAML},
+		{"yaml flow mapping", "logging: {level: info}\n", formatYAML},
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+		{"toml table", "[logging]\nlevel = \"info\"\n", formatTOML},
+		{"toml table with a comment", "# config\n[logging] # log settings\n", formatTOML},
+		{"toml dotted key", "logging.level = \"debug\"\n", formatTOML},
+		{"toml key", "name=\"x\"\n", formatTOML},
+		{"toml array of tables", "[[ai.prompts]]\nname = \"x\"\n", formatTOML},
+		{"toml quoted table", "[profiles.\"dev env\"]\n", formatTOML},
+{%- endif %}
+	}
+	for _, tt := range tests {
+		if got := sniffFormat([]byte(tt.data)); got != tt.want {
+			t.Errorf("%s: sniffFormat(%q) = %s, want %s", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFileFormatWithoutExtension(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"json": "{}\n",
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+		"yaml": "logging:\n  level: info\n",
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+		"toml": "[logging]\nlevel = \"info\"\n",
+{%- endif %}
+	}
+	for want, data := range files {
+		path := filepath.Join(dir, want+"-config")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := fileFormat(path); got != want {
+			t.Errorf("fileFormat(%s) = %s, want %s", filepath.Base(path), got, want)
+		}
+	}
+
+	// A missing file gets the preferred format
+	if got := fileFormat(filepath.Join(dir, "missing")); got != formats[0] {
+		t.Errorf("fileFormat(missing) = %s, want %s", got, formats[0])
+	}
+}
@@ -0,0 +1,312 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Layers of configuration files, lowest precedence first. Environment
+// variables and command-line flags override them all.
+const (
+	LayerSystem  = "system"  // /etc/${{values.name}}/config.<ext>
+	LayerUser    = "user"    // ~/.${{values.name}}/config.<ext>
+	LayerProject = "project" // .${{values.name}}.<ext> in the working directory or above
+	LayerFile    = "file"    // --config, read instead of the others
+)
+
+// envPrefix starts the names of the environment variables that override
+// config keys, e.g. ${{values.name|upper}}_AI_MODEL for ai.model
+var envPrefix = strings.ReplaceAll(strings.ToUpper("${{values.name}}"), "-", "_")
+
+// ProfileEnv is the environment variable that selects a profile when
+// --profile is not given
+var ProfileEnv = envPrefix + "_PROFILE"
+
+// profilesKey holds the named profiles in a config file
+const profilesKey = "profiles"
+
+// extensions are the config file types looked for, in order of preference
+var extensions = []string{
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	".yaml",
+	".yml",
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	".toml",
+{%- endif %}
+{%- if values.configFormat == "json" or values.configFormat == "all" %}
+	".json",
+{%- endif %}
+}
+
+// systemDir holds the system-wide config file
+var systemDir = filepath.Join("/etc", "${{values.name}}")
+
+// Source is a config file Load reads
+type Source struct {
+	Layer string `json:"layer" yaml:"layer"`
+	Path  string `json:"path" yaml:"path"`
+}
+
+// Sources returns the config files that exist, lowest precedence first. If
+// configFile is set, it is the only one.
+func Sources(configFile string) ([]Source, error) {
+	if configFile != "" {
+		source := Source{Layer: LayerFile, Path: configFile}
+		return []Source{source}, nil
+	}
+
+	var sources []Source
+	if path := find(systemDir, "config"); path != "" {
+		sources = append(sources, Source{Layer: LayerSystem, Path: path})
+	}
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	if path := find(dir, "config"); path != "" {
+		sources = append(sources, Source{Layer: LayerUser, Path: path})
+	}
+	path, err := findProject()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		sources = append(sources, Source{Layer: LayerProject, Path: path})
+	}
+	return sources, nil
+}
+
+// find returns the path of the config file named name in dir, or ""
+func find(dir, name string) string {
+	for _, ext := range extensions {
+		path := filepath.Join(dir, name+ext)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// findProject returns the nearest project config file in the working
+// directory or its parents, or ""
+func findProject() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	for {
+		if path := find(dir, ".${{values.name}}"); path != "" {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadLayers merges the config files, then the profile's settings from
+// them, then environment variables, into one map of config keys
+func loadLayers(configFile, profile string) (map[string]any, error) {
+	sources, err := Sources(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	var profiles []map[string]any
+	for _, s := range sources {
+		file, err := readFile(s.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateFile(s.Path, file); err != nil {
+			return nil, err
+		}
+		resolvePaths(file, filepath.Dir(s.Path))
+		if profile != "" {
+			if p, ok := profileSection(file, profile); ok {
+				profiles = append(profiles, p)
+			}
+		}
+		delete(file, profilesKey)
+		delete(file, schemaKey)
+		merge(values, file)
+	}
+
+	if profile != "" && len(profiles) == 0 {
+		return nil, fmt.Errorf("profile %q is not defined in any config file", profile)
+	}
+	for _, p := range profiles {
+		merge(values, p)
+	}
+
+	if err := applyEnv(values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// resolvePaths makes the relative paths in the keys tagged path of a config
+// file, and of its profiles, relative to dir, the file's directory, rather
+// than to the working directory
+func resolvePaths(values map[string]any, dir string) {
+	resolveSectionPaths(values, reflect.TypeOf(Config{}), dir)
+	if profiles, ok := values[profilesKey].(map[string]any); ok {
+		for _, profile := range profiles {
+			if section, ok := profile.(map[string]any); ok {
+				resolveSectionPaths(section, reflect.TypeOf(Config{}), dir)
+			}
+		}
+	}
+}
+
+// resolveSectionPaths resolves the paths of a section of struct type t
+func resolveSectionPaths(values map[string]any, t reflect.Type, dir string) {
+	for key, value := range values {
+		field, ok := fieldByKey(t, key)
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			if field.Type.Kind() == reflect.Struct {
+				resolveSectionPaths(v, field.Type, dir)
+			}
+		case string:
+			if field.Tag.Get("path") == "true" && v != "" && !filepath.IsAbs(v) {
+				values[key] = filepath.Join(dir, v)
+			}
+		}
+	}
+}
+
+// readFile decodes a config file into a map, in the format of its
+// extension or content
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	values, err := decodeValues(formatOf(path, data), data)
+	if err != nil {
+		return nil, syntaxError(path, data, err)
+	}
+	return values, nil
+}
+
+// profileSection returns the settings of the named profile in a config file
+func profileSection(file map[string]any, profile string) (map[string]any, bool) {
+	profiles, ok := file[profilesKey].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	section, ok := profiles[profile].(map[string]any)
+	return section, ok
+}
+
+// merge copies src into dst, merging nested maps and replacing other
+// values. Keys are lowercased, as viper does.
+func merge(dst, src map[string]any) {
+	for k, v := range src {
+		k = strings.ToLower(k)
+		if sm, ok := v.(map[string]any); ok {
+			dm, ok := dst[k].(map[string]any)
+			if !ok {
+				dm = map[string]any{}
+				dst[k] = dm
+			}
+			merge(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// applyEnv sets config keys from the environment variables named after
+// them, e.g. ${{values.name|upper}}_AI_CACHE_TTL_HOURS for ai.cache.ttl_hours. Lists
+// are comma-separated; lists of tables cannot be set this way.
+func applyEnv(values map[string]any) error {
+	var errs []error
+	walkKeys(reflect.TypeOf(Config{}), nil, func(path []string, t reflect.Type) {
+		name := EnvVar(strings.Join(path, "."))
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		value, err := parseValue(raw, t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+			return
+		}
+		set(values, path, value)
+	})
+	return errors.Join(errs...)
+}
+
+// walkKeys calls fn with the path and type of every config key that holds
+// a single value or a list of values
+func walkKeys(t reflect.Type, path []string, fn func(path []string, t reflect.Type)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "" || key == "-" || !field.IsExported() {
+			continue
+		}
+		keyPath := append(append([]string(nil), path...), key)
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			walkKeys(ft, keyPath, fn)
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.String,
+			ft.Kind() == reflect.Map:
+			// Not settable from a single variable
+		default:
+			fn(keyPath, ft)
+		}
+	}
+}
+
+// parseValue parses an environment variable as a value of type t
+func parseValue(raw string, t reflect.Type) (any, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(raw)
+	case reflect.Int, reflect.Int64:
+		return strconv.Atoi(raw)
+	case reflect.Float64:
+		return strconv.ParseFloat(raw, 64)
+	case reflect.Slice:
+		var list []any
+		for _, item := range strings.Split(raw, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+		return list, nil
+	default:
+		return raw, nil
+	}
+}
+
+// set stores value at path in values, creating nested maps as needed
+func set(values map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := values[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[path[len(path)-1]] = value
+}
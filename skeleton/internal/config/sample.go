@@ -0,0 +1,181 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// keyComments document the keys written by Marshal
+var keyComments = map[string]string{
+	"logging":        "Logging",
+	"logging.level":  "debug, info, warn or error",
+	"logging.format": "text or json",
+{%- if values.aiProvider != "none" %}
+
+	"ai":                "AI provider settings",
+	"ai.provider":       "bedrock, openai, anthropic, ollama or fake",
+	"ai.model":          "empty selects the provider's default model",
+	"ai.region":         "AWS region, for bedrock",
+	"ai.api_key":        "for openai and anthropic; best a reference: env:VAR, file:path, exec:command or secret:name",
+	"ai.host":           "server URL, for ollama",
+	"ai.cache":          "on-disk cache of AI responses",
+	"ai.cache.enabled":  "reuse responses to identical requests",
+	"ai.max_tokens":     "maximum tokens per response",
+	"ai.temperature":    "sampling temperature",
+	"ai.context_window": "tokens; default the model's known window",
+{%- endif %}
+{%- for integration in values.integrations %}
+
+	"{{integration}}": "{{integration|title}} settings",
+{%- endfor %}
+{%- if values.metrics %}
+
+	"metrics": "Prometheus metrics",
+{%- endif %}
+{%- if values.tracing %}
+
+	"tracing":          "OpenTelemetry tracing",
+	"tracing.endpoint": "OTLP HTTP endpoint",
+{%- endif %}
+}
+
+// fileHeader starts the files Marshal writes
+const fileHeader = "${{values.name}} configuration. Run `${{values.name}} config --help` for the commands that edit it."
+
+// Marshal encodes cfg as a config file in the format of path, with
+// comments where the format allows them. Keys that are empty and optional
+// are left out, as are lists of tables such as ai.prompts.
+func Marshal(cfg *Config, path string) ([]byte, error) {
+{%- if values.configFormat != "json" %}
+	if format := fileFormat(path); format != formatJSON {
+		var b strings.Builder
+		b.WriteString("# " + fileHeader + "\n")
+{%- if values.configFormat == "all" %}
+		if format == formatTOML {
+			writeTOML(&b, reflect.ValueOf(cfg).Elem(), nil)
+		} else {
+			writeYAML(&b, reflect.ValueOf(cfg).Elem(), nil)
+		}
+{%- elif values.configFormat == "toml" %}
+		writeTOML(&b, reflect.ValueOf(cfg).Elem(), nil)
+{%- else %}
+		writeYAML(&b, reflect.ValueOf(cfg).Elem(), nil)
+{%- endif %}
+		return []byte(b.String()), nil
+	}
+{%- endif %}
+
+	// JSON has no comments
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// sampleField is a key Marshal writes
+type sampleField struct {
+	key   string
+	value reflect.Value
+}
+
+// sampleFields returns the fields of struct value v that Marshal writes
+func sampleFields(v reflect.Value) []sampleField {
+	var fields []sampleField
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "" || key == "-" || !field.IsExported() {
+			continue
+		}
+		value := v.Field(i)
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+		switch {
+		case value.Kind() == reflect.Map,
+			value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			continue
+		case strings.Contains(opts, "omitempty") && value.IsZero():
+			continue
+		}
+		fields = append(fields, sampleField{key: key, value: value})
+	}
+	return fields
+}
+
+// writeComment writes the comment for the key at path, if it has one
+func writeComment(b *strings.Builder, path []string, indent string) {
+	if comment, ok := keyComments[strings.Join(path, ".")]; ok {
+		b.WriteString(indent + "# " + comment + "\n")
+	}
+}
+
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+// writeYAML writes the fields of struct value v as YAML, nested by path
+func writeYAML(b *strings.Builder, v reflect.Value, path []string) {
+	indent := strings.Repeat("  ", len(path))
+	for _, f := range sampleFields(v) {
+		keyPath := append(append([]string(nil), path...), f.key)
+		if len(path) == 0 {
+			b.WriteString("\n")
+		}
+		writeComment(b, keyPath, indent)
+		if f.value.Kind() == reflect.Struct {
+			b.WriteString(indent + f.key + ":\n")
+			writeYAML(b, f.value, keyPath)
+			continue
+		}
+		b.WriteString(indent + f.key + ": " + formatValue(f.value) + "\n")
+	}
+}
+{%- endif %}
+
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+
+// writeTOML writes the fields of struct value v as TOML: its values, then
+// a table for each section, named by path
+func writeTOML(b *strings.Builder, v reflect.Value, path []string) {
+	var sections []sampleField
+	for _, f := range sampleFields(v) {
+		if f.value.Kind() == reflect.Struct {
+			sections = append(sections, f)
+			continue
+		}
+		keyPath := append(append([]string(nil), path...), f.key)
+		writeComment(b, keyPath, "")
+		b.WriteString(f.key + " = " + formatValue(f.value) + "\n")
+	}
+	for _, f := range sections {
+		keyPath := append(append([]string(nil), path...), f.key)
+		b.WriteString("\n")
+		writeComment(b, keyPath, "")
+		b.WriteString("[" + strings.Join(keyPath, ".") + "]\n")
+		writeTOML(b, f.value, keyPath)
+	}
+}
+{%- endif %}
+
+// formatValue formats a single value or a list of strings in the syntax
+// YAML and TOML share
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatValue(v.Index(i))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
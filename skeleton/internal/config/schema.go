@@ -0,0 +1,117 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// schemaDialect is the JSON Schema version Schema generates
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns a JSON Schema for config files, generated from Config and
+// its validate tags, for editors to complete and check keys with
+func Schema() map[string]any {
+	configType := reflect.TypeOf(Config{})
+	schema := typeSchema(configType, "", false)
+	schema["$schema"] = schemaDialect
+	schema["title"] = "${{values.name}} configuration"
+
+	properties := schema["properties"].(map[string]any)
+	properties[schemaKey] = map[string]any{
+		"type":        "string",
+		"description": "JSON Schema of this file, for editors",
+	}
+	properties[profilesKey] = map[string]any{
+		"type":                 "object",
+		"description":          "named sets of keys, applied with --profile or " + ProfileEnv,
+		"additionalProperties": map[string]any{"$ref": "#/$defs/profile"},
+	}
+	schema["$defs"] = map[string]any{
+		"profile": typeSchema(configType, "", false),
+	}
+	return schema
+}
+
+// typeSchema returns the schema of a value of type t at key. Required
+// fields are only required in list items, as sections may be split across
+// files.
+func typeSchema(t reflect.Type, key string, item bool) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := map[string]any{}
+	if comment, ok := keyComments[key]; ok {
+		schema["description"] = comment
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			property := typeSchema(field.Type, joinKey(key, name), false)
+			addRules(property, field.Tag.Get("validate"))
+			properties[name] = property
+			if item && hasRule(field.Tag.Get("validate"), "required") {
+				required = append(required, name)
+			}
+		}
+		schema["type"] = "object"
+		schema["properties"] = properties
+		schema["additionalProperties"] = false
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	case reflect.Slice:
+		schema["type"] = "array"
+		schema["items"] = typeSchema(t.Elem(), key+"[]", t.Elem().Kind() == reflect.Struct)
+	case reflect.String:
+		schema["type"] = "string"
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int64:
+		schema["type"] = "integer"
+	case reflect.Float64:
+		schema["type"] = "number"
+	}
+	return schema
+}
+
+// addRules adds the schema keywords for the rules of a validate tag
+func addRules(schema map[string]any, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if schema["type"] == "string" {
+				schema["minLength"] = 1
+			}
+		case "oneof":
+			schema["enum"] = strings.Fields(arg)
+		case "url":
+			schema["format"] = "uri"
+		case "port":
+			schema["minimum"] = 0
+			schema["maximum"] = 65535
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			if name == "min" {
+				schema["minimum"] = limit
+			} else {
+				schema["maximum"] = limit
+			}
+		}
+	}
+}
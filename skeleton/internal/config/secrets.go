@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/secrets"
+)
+
+// The prefixes of secret references, which keys tagged secret may hold
+// instead of the secret itself
+const (
+	envRef    = "env:"    // env:VAR reads an environment variable
+	fileRef   = "file:"   // file:/path reads a file
+	execRef   = "exec:"   // exec:command prints the secret
+	secretRef = "secret:" // secret:name reads the encrypted secrets file
+)
+
+// execTimeout bounds the commands of exec: references
+const execTimeout = 30 * time.Second
+
+// redacted replaces the secrets that are not given as references when the
+// configuration is shown
+const redacted = "<redacted>"
+
+// PassphraseEnv is the environment variable that gives the passphrase of
+// a protected secrets key, for scripts and CI
+const PassphraseEnv = "${{values.name|upper}}_SECRETS_PASSPHRASE"
+
+var (
+	// store is the secrets store, shared so that its passphrase is asked
+	// for once
+	store     *secrets.Store
+	storeOnce sync.Once
+	storeErr  error
+
+	// resolved holds the secrets resolved so far, by reference, so that
+	// each exec: command runs once
+	resolved   = map[string]string{}
+	resolvedMu sync.Mutex
+)
+
+// Secrets returns the store of the encrypted secrets file in Dir
+func Secrets() (*secrets.Store, error) {
+	storeOnce.Do(func() {
+		var dir string
+		if dir, storeErr = Dir(); storeErr != nil {
+			return
+		}
+		store = secrets.NewStore(dir)
+		store.Passphrase = passphrase
+	})
+	return store, storeErr
+}
+
+// passphrase returns the passphrase of the secrets key from PassphraseEnv,
+// or asks for it on the terminal
+func passphrase() (string, error) {
+	if p, ok := os.LookupEnv(PassphraseEnv); ok {
+		return p, nil
+	}
+	p, err := secrets.ReadPassphrase("Passphrase for the secrets key")
+	if err != nil {
+		return "", fmt.Errorf("the secrets key is protected by a passphrase; set %s: %w", PassphraseEnv, err)
+	}
+	return p, nil
+}
+
+// IsSecret reports whether a config key, such as ai.api_key or
+// profiles.dev.ai.api_key, holds a secret
+func IsSecret(key string) bool {
+	path := strings.Split(strings.ToLower(key), ".")
+	if path[0] == profilesKey && len(path) > 2 {
+		path = path[2:]
+	}
+	t := reflect.TypeOf(Config{})
+	for i, name := range path {
+		field, ok := fieldByKey(t, name)
+		if !ok {
+			return false
+		}
+		if i == len(path)-1 {
+			return field.Tag.Get("secret") == "true"
+		}
+		t = field.Type
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+	}
+	return false
+}
+
+// IsReference reports whether value is a secret reference rather than a
+// secret
+func IsReference(value string) bool {
+	for _, prefix := range []string{envRef, fileRef, execRef, secretRef} {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the secret value refers to if it is a secret reference,
+// or value itself. Load keeps references as they are written, so that
+// exec: commands run and passphrases are asked for only by commands that
+// use the secret: code that uses a key tagged secret, such as a provider's
+// API key, resolves it with Resolve first.
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	resolvedMu.Lock()
+	defer resolvedMu.Unlock()
+	if secret, ok := resolved[value]; ok {
+		return secret, nil
+	}
+	secret, err := resolveSecret(value)
+	if err != nil {
+		return "", err
+	}
+	resolved[value] = secret
+	return secret, nil
+}
+
+// ResolveSecrets resolves, in place, every secret reference in the fields
+// tagged secret of the struct v points to, such as an integration's config
+func ResolveSecrets(v any) error {
+	var errs []error
+	walkValues(reflect.ValueOf(v).Elem(), "", func(key string, field reflect.StructField, v reflect.Value) {
+		if field.Tag.Get("secret") != "true" {
+			return
+		}
+		secret, err := Resolve(v.String())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			return
+		}
+		v.SetString(secret)
+	})
+	return errors.Join(errs...)
+}
+
+// resolveSecret returns the secret a reference refers to
+func resolveSecret(ref string) (string, error) {
+	prefix, target, _ := strings.Cut(ref, ":")
+	if target == "" {
+		return "", fmt.Errorf("%s reference is empty", prefix)
+	}
+
+	switch prefix + ":" {
+	case envRef:
+		secret, ok := os.LookupEnv(target)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", target)
+		}
+		return secret, nil
+	case fileRef:
+		if rest, ok := strings.CutPrefix(target, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", fmt.Errorf("failed to get home directory: %w", err)
+			}
+			target = filepath.Join(home, rest)
+		}
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case execRef:
+		return execSecret(target)
+	default:
+		store, err := Secrets()
+		if err != nil {
+			return "", err
+		}
+		secret, err := store.Get(target)
+		if errors.Is(err, secrets.ErrNotFound) {
+			return "", fmt.Errorf("secret %q is not set; set it with `${{values.name}} secrets set %s`", target, target)
+		}
+		return secret, err
+	}
+}
+
+// execSecret runs command with the shell and returns what it prints. The
+// command may prompt on the terminal, as password managers do.
+func execSecret(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+
+	secret := strings.TrimSpace(stdout.String())
+	if secret == "" {
+		return "", fmt.Errorf("command %q printed nothing", command)
+	}
+	return secret, nil
+}
+
+// Redacted returns a copy of the configuration that is safe to show: each
+// secret given as a reference keeps it, and the others are hidden
+func (c *Config) Redacted() *Config {
+	r := *c
+	walkValues(reflect.ValueOf(&r).Elem(), "", func(key string, field reflect.StructField, v reflect.Value) {
+		if field.Tag.Get("secret") != "true" || v.String() == "" || IsReference(v.String()) {
+			return
+		}
+		v.SetString(redacted)
+	})
+	return &r
+}
@@ -0,0 +1,730 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"reflect"
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	"regexp"
+{%- endif %}
+	"sort"
+	"strconv"
+	"strings"
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+	"gopkg.in/yaml.v3"
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+{%- endif %}
+)
+
+// schemaKey may name a JSON Schema in a config file, for editors
+const schemaKey = "$schema"
+
+// Position is a line and column in a config file, counted from 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+// FieldError is a problem with a config file or one of its keys. Validate
+// and Load return them joined, sorted by position.
+type FieldError struct {
+	// Source is the config file, or the environment variable as
+	// $NAME, the value came from; empty for the defaults
+	Source string
+	// Position is zero when it is not known
+	Position
+	// Key is the dotted key, with [n] for list items; empty for syntax
+	// errors
+	Key     string
+	Message string
+}
+
+// Error formats the error as source:line:column: key: message
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	if e.Source != "" {
+		b.WriteString(e.Source)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d", e.Line)
+			if e.Column > 0 {
+				fmt.Fprintf(&b, ":%d", e.Column)
+			}
+		}
+		b.WriteString(": ")
+	}
+	if e.Key != "" {
+		b.WriteString(e.Key + ": ")
+	}
+	b.WriteString(e.Message)
+	return b.String()
+}
+
+// Validate checks a config file: that it parses, that every key is known,
+// that every value has the right type, and that values follow the rules in
+// the validate tags of Config
+func Validate(path string) error {
+	values, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	return validateFile(path, values)
+}
+
+// validateFile checks the decoded values of a config file, and locates the
+// problems in the file
+func validateFile(path string, values map[string]any) error {
+	errs := checkFile(values)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	var positions map[string]Position
+	if data, err := os.ReadFile(path); err == nil {
+		positions = keyPositions(path, data)
+	}
+	for _, e := range errs {
+		e.Source = path
+		e.Position = findPosition(positions, e.Key)
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	return joinFieldErrors(errs)
+}
+
+// joinFieldErrors joins errs into one error, or returns nil
+func joinFieldErrors(errs []*FieldError) error {
+	joined := make([]error, len(errs))
+	for i, e := range errs {
+		joined[i] = e
+	}
+	return errors.Join(joined...)
+}
+
+// checkFile checks the keys and values of a config file, including its
+// profiles
+func checkFile(values map[string]any) []*FieldError {
+	configType := reflect.TypeOf(Config{})
+	rest := map[string]any{}
+	var errs []*FieldError
+	for name, value := range values {
+		switch strings.ToLower(name) {
+		case schemaKey:
+		case profilesKey:
+			profiles, ok := value.(map[string]any)
+			if !ok {
+				errs = append(errs, &FieldError{Key: profilesKey, Message: "must map profile names to sections of keys"})
+				continue
+			}
+			for profile, section := range profiles {
+				key := profilesKey + "." + strings.ToLower(profile)
+				keys, ok := section.(map[string]any)
+				if !ok {
+					errs = append(errs, &FieldError{Key: key, Message: "must be a section of keys"})
+					continue
+				}
+				errs = append(errs, checkSection(keys, configType, key)...)
+			}
+		default:
+			rest[name] = value
+		}
+	}
+	return append(errs, checkSection(rest, configType, "")...)
+}
+
+// checkSection checks the keys and values of a section against the fields
+// of struct type t
+func checkSection(values map[string]any, t reflect.Type, prefix string) []*FieldError {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []*FieldError
+	for _, name := range names {
+		key := joinKey(prefix, strings.ToLower(name))
+		field, ok := fieldByKey(t, strings.ToLower(name))
+		if !ok {
+			msg := "unknown key"
+			if suggestion := suggestKey(t, strings.ToLower(name), prefix == ""); suggestion != "" {
+				msg += fmt.Sprintf("; did you mean %s?", joinKey(prefix, suggestion))
+			}
+			errs = append(errs, &FieldError{Key: key, Message: msg})
+			continue
+		}
+		errs = append(errs, checkValue(values[name], field, key)...)
+	}
+	return errs
+}
+
+// checkValue checks a value from a config file against its field
+func checkValue(value any, field reflect.StructField, key string) []*FieldError {
+	// An empty YAML value, such as "model:", leaves the key unset
+	if value == nil {
+		return nil
+	}
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		section, ok := value.(map[string]any)
+		if !ok {
+			return keyError(key, "must be a section of keys")
+		}
+		return checkSection(section, t, key)
+	case reflect.Slice:
+		list, ok := value.([]any)
+		if !ok {
+			return keyError(key, "must be a list")
+		}
+		var errs []*FieldError
+		for i, item := range list {
+			itemKey := fmt.Sprintf("%s[%d]", key, i)
+			if t.Elem().Kind() != reflect.Struct {
+				if msg := typeError(item, t.Elem()); msg != "" {
+					errs = append(errs, &FieldError{Key: itemKey, Message: msg})
+				}
+				continue
+			}
+			table, ok := item.(map[string]any)
+			if !ok {
+				errs = append(errs, &FieldError{Key: itemKey, Message: "must be a section of keys"})
+				continue
+			}
+			// A list item is complete in one file, unlike a section
+			errs = append(errs, checkSection(table, t.Elem(), itemKey)...)
+			errs = append(errs, checkRequired(table, t.Elem(), itemKey)...)
+		}
+		return errs
+	}
+
+	if msg := typeError(value, t); msg != "" {
+		return keyError(key, msg)
+	}
+	if msg := ruleError(value, field.Tag.Get("validate")); msg != "" {
+		return keyError(key, msg)
+	}
+	return nil
+}
+
+// CheckValue checks a value for a config key, such as a command-line flag
+// that overrides ai.temperature, against the key's type and validate rules.
+// The error is the reason alone, such as "must be at most 2".
+func CheckValue(key string, value any) error {
+	t := reflect.TypeOf(Config{})
+	var field reflect.StructField
+	for _, name := range strings.Split(key, ".") {
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		var ok bool
+		if field, ok = fieldByKey(t, name); !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		t = field.Type
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+
+	msg := typeError(value, t)
+	if msg == "" {
+		msg = ruleError(value, field.Tag.Get("validate"))
+	}
+	if msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// keyError returns a single error about key
+func keyError(key, msg string) []*FieldError {
+	e := &FieldError{Key: key, Message: msg}
+	return []*FieldError{e}
+}
+
+// checkRequired returns an error for every required field of struct type t
+// that a list item leaves out
+func checkRequired(table map[string]any, t reflect.Type, prefix string) []*FieldError {
+	present := map[string]bool{}
+	for name := range table {
+		present[strings.ToLower(name)] = true
+	}
+
+	var errs []*FieldError
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if hasRule(field.Tag.Get("validate"), "required") && !present[key] {
+			errs = append(errs, &FieldError{Key: joinKey(prefix, key), Message: "is required"})
+		}
+	}
+	return errs
+}
+
+// typeError returns why value cannot be decoded as type t, or ""
+func typeError(value any, t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			return "must be a string"
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case reflect.Int, reflect.Int64:
+		if n, ok := toFloat(value); !ok || n != math.Trunc(n) {
+			return "must be a whole number"
+		}
+	case reflect.Float64:
+		if _, ok := toFloat(value); !ok {
+			return "must be a number"
+		}
+	}
+	return ""
+}
+
+// toFloat returns a decoded number as a float64
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// ruleError returns why value breaks a rule of a validate tag, or "".
+// Empty values pass every rule but required.
+//
+//	required     the value must not be empty
+//	oneof=a b c  the value must be one of the words
+//	url          the value must be an absolute URL
+//	port         the value must be a port number
+//	min=n, max=n the value must be at least or at most n
+func ruleError(value any, tag string) string {
+	if tag == "" {
+		return ""
+	}
+	s, _ := value.(string)
+	n, isNumber := toFloat(value)
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if value == nil || s == "" && !isNumber || isNumber && n == 0 {
+				return "is required"
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if s != "" && !contains(allowed, s) {
+				return "must be " + orList(allowed)
+			}
+		case "url":
+			if s == "" {
+				continue
+			}
+			if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+				return "must be a URL, such as http://localhost:8080"
+			}
+		case "port":
+			if isNumber && n != 0 && (n < 1 || n > 65535) {
+				return "must be a port number from 1 to 65535"
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil || !isNumber {
+				continue
+			}
+			if name == "min" && n < limit {
+				return "must be at least " + arg
+			}
+			if name == "max" && n > limit {
+				return "must be at most " + arg
+			}
+		}
+	}
+	return ""
+}
+
+// hasRule reports whether a validate tag has the named rule
+func hasRule(tag, name string) bool {
+	for _, rule := range strings.Split(tag, ",") {
+		if r, _, _ := strings.Cut(rule, "="); r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfig checks the merged configuration against the rules in the
+// validate tags, which catches bad values from environment variables. The
+// files are checked, with positions, as they are read.
+func validateConfig(cfg *Config) error {
+	var errs []*FieldError
+	walkValues(reflect.ValueOf(cfg).Elem(), "", func(key string, field reflect.StructField, v reflect.Value) {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+		msg := ruleError(v.Interface(), field.Tag.Get("validate"))
+		if msg == "" {
+			return
+		}
+		e := &FieldError{Key: key, Message: msg}
+		if _, ok := os.LookupEnv(EnvVar(key)); ok {
+			e.Source = "$" + EnvVar(key)
+		}
+		errs = append(errs, e)
+	})
+	return joinFieldErrors(errs)
+}
+
+// walkValues calls fn with the key, field and value of every single value
+// in struct value v, including those in lists of tables
+func walkValues(v reflect.Value, prefix string, fn func(key string, field reflect.StructField, v reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := joinKey(prefix, name)
+		value := v.Field(i)
+		switch {
+		case value.Kind() == reflect.Struct:
+			walkValues(value, key, fn)
+		case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < value.Len(); j++ {
+				walkValues(value.Index(j), fmt.Sprintf("%s[%d]", key, j), fn)
+			}
+		default:
+			fn(key, field, value)
+		}
+	}
+}
+
+// suggestKey returns the field of struct type t whose key is closest to an
+// unknown key, if one is close enough to be a typo
+func suggestKey(t reflect.Type, unknown string, top bool) string {
+	var candidates []string
+	for i := 0; i < t.NumField(); i++ {
+		if key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); key != "" && key != "-" {
+			candidates = append(candidates, key)
+		}
+	}
+	if top {
+		candidates = append(candidates, profilesKey)
+	}
+
+	best, bestDistance := "", 3
+	for _, c := range candidates {
+		if d := editDistance(unknown, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// joinKey appends a key to a dotted prefix
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// contains reports whether list contains s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// orList formats words as "a, b or c"
+func orList(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return "one of " + strings.Join(words[:len(words)-1], ", ") + " or " + words[len(words)-1]
+}
+
+// findPosition returns the position of a key, or of its nearest enclosing
+// key or list item that has one
+func findPosition(positions map[string]Position, key string) Position {
+	for key != "" {
+		if pos, ok := positions[key]; ok {
+			return pos
+		}
+		i := strings.LastIndexAny(key, ".[")
+		if i < 0 {
+			break
+		}
+		key = key[:i]
+	}
+	return Position{}
+}
+
+// keyPositions returns the positions of the keys and list items in a config
+// file, by dotted key, or nil if it does not parse
+func keyPositions(path string, data []byte) map[string]Position {
+	switch formatOf(path, data) {
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+	case formatYAML:
+		return yamlPositions(data)
+{%- endif %}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+	case formatTOML:
+		return tomlPositions(data)
+{%- endif %}
+	default:
+		return jsonPositions(data)
+	}
+}
+
+// offsetPosition returns the position of a byte offset in data
+func offsetPosition(data []byte, offset int) Position {
+	lead := data[:offset]
+	return Position{
+		Line:   bytes.Count(lead, []byte{'\n'}) + 1,
+		Column: offset - bytes.LastIndexByte(lead, '\n'),
+	}
+}
+
+// jsonPositions returns the positions of the keys and list items in a JSON
+// document
+func jsonPositions(data []byte) map[string]Position {
+	positions := map[string]Position{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) bool
+	walk = func(path string) bool {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				tok, err := dec.Token()
+				if err != nil {
+					return false
+				}
+				name, _ := tok.(string)
+				key := joinKey(path, strings.ToLower(name))
+				// The key ends at the offset; find its opening quote
+				end := int(dec.InputOffset())
+				positions[key] = offsetPosition(data, bytes.LastIndexByte(data[:end-1], '"'))
+				if !walk(key) {
+					return false
+				}
+			}
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				start := int(dec.InputOffset())
+				start += len(data[start:]) - len(bytes.TrimLeft(data[start:], " \t\r\n,"))
+				key := fmt.Sprintf("%s[%d]", path, i)
+				positions[key] = offsetPosition(data, start)
+				if !walk(key) {
+					return false
+				}
+			}
+		default:
+			return true
+		}
+		// The closing delimiter
+		_, err = dec.Token()
+		return err == nil
+	}
+	walk("")
+	return positions
+}
+
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+// yamlPositions returns the positions of the keys and list items in a YAML
+// document
+func yamlPositions(data []byte) map[string]Position {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+
+	positions := map[string]Position{}
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyNode := node.Content[i]
+				key := joinKey(path, strings.ToLower(keyNode.Value))
+				positions[key] = Position{Line: keyNode.Line, Column: keyNode.Column}
+				walk(node.Content[i+1], key)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				key := fmt.Sprintf("%s[%d]", path, i)
+				positions[key] = Position{Line: item.Line, Column: item.Column}
+				walk(item, key)
+			}
+		}
+	}
+	walk(doc.Content[0], "")
+	return positions
+}
+{%- endif %}
+
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+
+// tomlPositions returns the positions of the keys and list items in a TOML
+// document
+func tomlPositions(data []byte) map[string]Position {
+	positions := map[string]Position{}
+	var p unstable.Parser
+	p.Reset(data)
+
+	// keys records the positions of a dotted key and its parents under
+	// prefix, and returns the full key
+	keys := func(prefix string, it unstable.Iterator) string {
+		key := prefix
+		for it.Next() {
+			node := it.Node()
+			key = joinKey(key, strings.ToLower(string(node.Data)))
+			if _, ok := positions[key]; !ok {
+				start := p.Shape(node.Raw).Start
+				positions[key] = Position{Line: start.Line, Column: start.Column}
+			}
+		}
+		return key
+	}
+
+	// values records the positions of the keys in an inline table or
+	// array under key
+	var values func(key string, node *unstable.Node)
+	values = func(key string, node *unstable.Node) {
+		switch node.Kind {
+		case unstable.InlineTable:
+			children := node.Children()
+			for children.Next() {
+				kv := children.Node()
+				values(keys(key, kv.Key()), kv.Value())
+			}
+		case unstable.Array:
+			children := node.Children()
+			for i := 0; children.Next(); i++ {
+				itemKey := fmt.Sprintf("%s[%d]", key, i)
+				positions[itemKey] = positions[key]
+				values(itemKey, children.Node())
+			}
+		}
+	}
+
+	table := ""
+	arrayTables := map[string]int{}
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.Table:
+			table = keys("", expr.Key())
+		case unstable.ArrayTable:
+			name := keys("", expr.Key())
+			table = fmt.Sprintf("%s[%d]", name, arrayTables[name])
+			arrayTables[name]++
+			positions[table] = positions[name]
+		case unstable.KeyValue:
+			values(keys(table, expr.Key()), expr.Value())
+		}
+	}
+	return positions
+}
+{%- endif %}
+
+// syntaxError converts a decoding error into a FieldError located in the
+// file, where the decoder reports the position
+func syntaxError(path string, data []byte, err error) error {
+	e := &FieldError{Source: path, Message: err.Error()}
+
+	var jsonSyntax *json.SyntaxError
+	var jsonType *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &jsonSyntax):
+		// The offset is just past the offending character
+		e.Position = offsetPosition(data, int(jsonSyntax.Offset)-1)
+		e.Message = jsonSyntax.Error()
+	case errors.As(err, &jsonType):
+		e.Position = offsetPosition(data, int(jsonType.Offset))
+		e.Message = "expected a section of keys"
+	}
+{%- if values.configFormat == "toml" or values.configFormat == "all" %}
+
+	var tomlErr *toml.DecodeError
+	if errors.As(err, &tomlErr) {
+		e.Line, e.Column = tomlErr.Position()
+		e.Message = strings.TrimPrefix(tomlErr.Error(), "toml: ")
+	}
+{%- endif %}
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+	if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Message = m[2]
+	}
+{%- endif %}
+	return e
+}
+
+{%- if values.configFormat == "yaml" or values.configFormat == "all" %}
+
+// yamlLine matches the line number in a YAML syntax error
+var yamlLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+{%- endif %}
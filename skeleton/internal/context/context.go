@@ -2,6 +2,9 @@
 package context
 
 import (
+{%- if values.integrations|length > 0 %}
+	"fmt"
+{%- endif %}
 	"sync"
 
 	"github.com/fast-ish/${{values.name}}/internal/config"
@@ -21,16 +24,25 @@ type Context struct {
 	Verbose int
 	DryRun  bool
 
+	// ConfigFile is the --config file, if given
+	ConfigFile string
+	// ConfigErr is the error loading the configuration, for the config
+	// commands, which run without it to help fix it. Config then holds the
+	// defaults.
+	ConfigErr error
+
 {%- if values.aiProvider != "none" %}
 	// AI client (lazy-loaded)
 	aiOnce   sync.Once
 	aiClient *ai.Client
+	aiErr    error
 {%- endif %}
 
 {%- for integration in values.integrations %}
 	// {{integration|title}} client (lazy-loaded)
 	{{integration}}Once   sync.Once
 	{{integration}}Client *{{integration}}.Client
+	{{integration}}Err    error
 {%- endfor %}
 }
 
@@ -44,23 +56,79 @@ func NewContext(cfg *config.Config) *Context {
 
 {%- if values.aiProvider != "none" %}
 
-// AI returns the AI client (lazy-loaded)
-func (c *Context) AI() *ai.Client {
+// AI returns the AI client (lazy-loaded). The error from creating it is
+// returned on every call.
+func (c *Context) AI() (*ai.Client, error) {
 	c.aiOnce.Do(func() {
-		c.aiClient = ai.NewClient(c.Config.AI)
+		c.aiClient, c.aiErr = c.NewAI(c.Config.AI)
 	})
-	return c.aiClient
+	return c.aiClient, c.aiErr
+}
+
+// NewAI creates an AI client for cfg with the usage ledger, prompts,
+// recorder and cache set up like the one AI returns, for commands that use
+// several models
+func (c *Context) NewAI(cfg config.AIConfig) (*ai.Client, error) {
+	client, err := ai.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// Usage accounting is best effort
+	if ledger, err := ai.DefaultLedger(); err == nil {
+		client.SetLedger(ledger)
+	}
+	prompts, err := ai.LoadPrompts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.SetPrompts(prompts)
+	if cfg.Record != "" {
+		client.SetRecorder(ai.NewRecorder(cfg.Record))
+	}
+	if cfg.Cache.Enabled || cfg.Cache.Only {
+		cache, err := ai.DefaultCache(cfg.Cache)
+		if err != nil {
+			return nil, err
+		}
+		client.SetCache(cache, cfg.Cache.Only)
+	}
+	return client, nil
+}
+
+// Tools returns the AI tools exposed by the configured integrations.
+// Integration clients expose tools by implementing ai.ToolProvider.
+func (c *Context) Tools() (*ai.ToolRegistry, error) {
+	tools := ai.NewToolRegistry()
+{%- for integration in values.integrations %}
+	{{integration}}Client, err := c.{{integration|title}}()
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := any({{integration}}Client).(ai.ToolProvider); ok {
+		if err := tools.Register(p.Tools()...); err != nil {
+			return nil, err
+		}
+	}
+{%- endfor %}
+	return tools, nil
 }
 {%- endif %}
 
 {%- for integration in values.integrations %}
 
-// {{integration|title}} returns the {{integration}} client (lazy-loaded)
-func (c *Context) {{integration|title}}() *{{integration}}.Client {
+// {{integration|title}} returns the {{integration}} client (lazy-loaded), once the
+// secret references in its config are resolved. The error is returned on
+// every call.
+func (c *Context) {{integration|title}}() (*{{integration}}.Client, error) {
 	c.{{integration}}Once.Do(func() {
-		c.{{integration}}Client = {{integration}}.NewClient(c.Config.{{integration|title}})
+		cfg := c.Config.{{integration|title}}
+		if err := config.ResolveSecrets(&cfg); err != nil {
+			c.{{integration}}Err = fmt.Errorf("{{integration}}: %w", err)
+			return
+		}
+		c.{{integration}}Client = {{integration}}.NewClient(cfg)
 	})
-	return c.{{integration}}Client
+	return c.{{integration}}Client, c.{{integration}}Err
 }
 {%- endfor %}
 
@@ -0,0 +1,414 @@
+{%- if values.aiProvider != "none" %}
+// Package eval runs suites of prompt evaluations against AI models and grades
+// the outputs, to catch regressions when prompts or models change
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+)
+
+// Suite is a set of cases run through a prompt on one or more models
+type Suite struct {
+	Name string `yaml:"name"`
+	// Prompt names a prompt in the library; Template is an inline
+	// text/template used instead
+	Prompt   string `yaml:"prompt"`
+	Template string `yaml:"template"`
+	// Models are compared; empty runs the configured model only
+	Models []Target `yaml:"models"`
+	// Judge grades judge expectations; nil uses the first model
+	Judge *Target `yaml:"judge"`
+	Cases []Case  `yaml:"cases"`
+
+	prompt *ai.Prompt
+}
+
+// Target selects a model, and optionally a provider other than the
+// configured one
+type Target struct {
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+}
+
+// Case is an input and the expectations its output must meet
+type Case struct {
+	Name string `yaml:"name"`
+	// Input is the prompt's .Text; InputFile reads it from a file relative
+	// to the suite
+	Input     string            `yaml:"input"`
+	InputFile string            `yaml:"input_file"`
+	Vars      map[string]string `yaml:"vars"`
+	Expect    []Expectation     `yaml:"expect"`
+}
+
+// Expectation grades an output. Exactly one field is set.
+type Expectation struct {
+	Exact  string `yaml:"exact"`  // the whole output, ignoring surrounding space
+	Regex  string `yaml:"regex"`  // matches somewhere in the output
+	Schema string `yaml:"schema"` // JSON Schema file the output must match, relative to the suite
+	Judge  string `yaml:"judge"`  // criteria the judge model checks the output against
+
+	re     *regexp.Regexp
+	schema *ai.Schema
+}
+
+// Result is the outcome of one case on one model
+type Result struct {
+	Case     string        `json:"case" yaml:"case"`
+	Model    string        `json:"model" yaml:"model"`
+	Output   string        `json:"output" yaml:"output"`
+	Passed   int           `json:"passed" yaml:"passed"` // expectations met
+	Total    int           `json:"total" yaml:"total"`
+	Failures []string      `json:"failures,omitempty" yaml:"failures,omitempty"`
+	Err      string        `json:"error,omitempty" yaml:"error,omitempty"` // the model call failed
+	Latency  time.Duration `json:"latency" yaml:"latency"`                 // nanoseconds in JSON
+}
+
+// OK reports whether the output met every expectation
+func (r Result) OK() bool {
+	return r.Err == "" && r.Passed == r.Total
+}
+
+// Load reads and checks a suite file
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+
+	var s Suite
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := s.prepare(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// prepare checks the suite, reads input files and compiles expectations.
+// Paths are relative to dir.
+func (s *Suite) prepare(dir string) error {
+	switch {
+	case s.Prompt != "" && s.Template != "":
+		return errors.New("set prompt or template, not both")
+	case s.Template != "":
+		prompt, err := ai.NewPrompt(s.Name, "", "suite", s.Template)
+		if err != nil {
+			return err
+		}
+		s.prompt = prompt
+	case s.Prompt == "":
+		return errors.New("set prompt or template")
+	}
+	if len(s.Cases) == 0 {
+		return errors.New("no cases")
+	}
+
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+		}
+		if c.InputFile != "" {
+			data, err := os.ReadFile(resolve(dir, c.InputFile))
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.Name, err)
+			}
+			c.Input = string(data)
+		}
+		if len(c.Expect) == 0 {
+			return fmt.Errorf("%s: no expectations", c.Name)
+		}
+		for j := range c.Expect {
+			if err := c.Expect[j].prepare(dir); err != nil {
+				return fmt.Errorf("%s: expectation %d: %w", c.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Expectation) prepare(dir string) error {
+	set := 0
+	for _, v := range []string{e.Exact, e.Regex, e.Schema, e.Judge} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("set exactly one of exact, regex, schema and judge")
+	}
+
+	var err error
+	switch {
+	case e.Regex != "":
+		e.re, err = regexp.Compile(e.Regex)
+	case e.Schema != "":
+		e.schema, err = ai.LoadSchema(resolve(dir, e.Schema))
+	}
+	return err
+}
+
+// NeedsJudge reports whether any case has a judge expectation
+func (s *Suite) NeedsJudge() bool {
+	for _, c := range s.Cases {
+		for _, e := range c.Expect {
+			if e.Judge != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Run runs every case on every client and grades the outputs, using judge
+// for judge expectations. A failed model call fails the case, not the run.
+func Run(ctx context.Context, s *Suite, clients []*ai.Client, judge *ai.Client) (*Report, error) {
+	report := &Report{Suite: s.Name}
+	for _, client := range clients {
+		for _, c := range s.Cases {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			report.Results = append(report.Results, s.run(ctx, c, client, judge))
+		}
+	}
+	return report, nil
+}
+
+// run runs one case on one client
+func (s *Suite) run(ctx context.Context, c Case, client, judge *ai.Client) Result {
+	result := Result{Case: c.Name, Model: ModelName(client), Total: len(c.Expect)}
+
+	vars := map[string]string{"Text": c.Input}
+	for k, v := range c.Vars {
+		vars[k] = v
+	}
+	var prompt string
+	var err error
+	if s.prompt != nil {
+		prompt, err = s.prompt.Render(vars)
+	} else {
+		prompt, err = client.Prompts().Render(s.Prompt, vars)
+	}
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	output, err := client.Converse(ctx, []ai.Message{
+		{Role: ai.RoleUser, Content: prompt},
+	})
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+	result.Output = output
+
+	for _, e := range c.Expect {
+		if failure := e.grade(ctx, prompt, output, judge); failure != "" {
+			result.Failures = append(result.Failures, failure)
+		} else {
+			result.Passed++
+		}
+	}
+	return result
+}
+
+// grade checks output against the expectation and describes the failure,
+// or returns "" if it is met
+func (e Expectation) grade(ctx context.Context, prompt, output string, judge *ai.Client) string {
+	switch {
+	case e.Exact != "":
+		if strings.TrimSpace(output) != strings.TrimSpace(e.Exact) {
+			return fmt.Sprintf("exact: output is not %q", e.Exact)
+		}
+	case e.re != nil:
+		if !e.re.MatchString(output) {
+			return fmt.Sprintf("regex: output does not match %s", e.Regex)
+		}
+	case e.schema != nil:
+		if _, err := e.schema.Validate([]byte(ai.StripCodeFence(output))); err != nil {
+			return fmt.Sprintf("schema %s: %v", e.Schema, err)
+		}
+	case e.Judge != "":
+		verdict, err := judge.Judge(ctx, e.Judge, prompt, output)
+		if err != nil {
+			return fmt.Sprintf("judge: %v", err)
+		}
+		if !verdict.Pass {
+			return fmt.Sprintf("judge: %s", verdict.Reason)
+		}
+	}
+	return ""
+}
+
+// ModelName names a client's model in reports, e.g. openai/gpt-4o
+func ModelName(client *ai.Client) string {
+	return client.Provider() + "/" + client.Model()
+}
+
+// Report is the result of running a suite
+type Report struct {
+	Suite   string   `json:"suite" yaml:"suite"`
+	Results []Result `json:"results" yaml:"results"`
+}
+
+// Failed returns the results that did not meet every expectation
+func (r *Report) Failed() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.OK() {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Summary describes how many cases passed on each model, e.g.
+// "openai/gpt-4o 3/4, ollama/llama3 2/4"
+func (r *Report) Summary() string {
+	var models []string
+	passed, total := map[string]int{}, map[string]int{}
+	for _, res := range r.Results {
+		if total[res.Model] == 0 {
+			models = append(models, res.Model)
+		}
+		total[res.Model]++
+		if res.OK() {
+			passed[res.Model]++
+		}
+	}
+	parts := make([]string, len(models))
+	for i, m := range models {
+		parts[i] = fmt.Sprintf("%s %d/%d", m, passed[m], total[m])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Rows returns a table row per case and model
+func (r *Report) Rows() []map[string]any {
+	rows := make([]map[string]any, 0, len(r.Results))
+	for _, res := range r.Results {
+		status, detail := "pass", ""
+		switch {
+		case res.Err != "":
+			status, detail = "error", res.Err
+		case !res.OK():
+			status, detail = "fail", strings.Join(res.Failures, "; ")
+		}
+		rows = append(rows, map[string]any{
+			"case":    res.Case,
+			"model":   res.Model,
+			"result":  status,
+			"score":   fmt.Sprintf("%d/%d", res.Passed, res.Total),
+			"latency": res.Latency.Round(time.Millisecond).String(),
+			"detail":  detail,
+		})
+	}
+	return rows
+}
+
+// JUnit returns the report as JUnit XML, with a test suite per model
+func (r *Report) JUnit() any {
+	out := junitSuites{Name: r.Suite}
+	index := map[string]int{}
+	for _, res := range r.Results {
+		i, ok := index[res.Model]
+		if !ok {
+			i = len(out.Suites)
+			index[res.Model] = i
+			out.Suites = append(out.Suites, junitSuite{Name: r.Suite + " (" + res.Model + ")"})
+		}
+		suite := &out.Suites[i]
+
+		tc := junitCase{Name: res.Case, Classname: res.Model, Time: seconds(res.Latency)}
+		switch {
+		case res.Err != "":
+			tc.Error = &junitMessage{Message: res.Err, Text: res.Err}
+			suite.Errors++
+			out.Errors++
+		case !res.OK():
+			tc.Failure = &junitMessage{Message: res.Failures[0], Text: strings.Join(res.Failures, "\n")}
+			tc.SystemOut = res.Output
+			suite.Failures++
+			out.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		out.Tests++
+		suite.latency += res.Latency
+		suite.Time = seconds(suite.latency)
+	}
+	return out
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+
+	latency time.Duration
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// resolve returns path relative to dir unless it is absolute
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+{%- else %}
+package eval
+{%- endif %}
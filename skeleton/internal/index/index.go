@@ -0,0 +1,454 @@
+{%- if values.aiProvider != "none" %}
+// Package index maintains local vector indexes of documents, used to answer
+// questions from their most relevant passages
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// ErrNotFound is returned when no index has the given name
+var ErrNotFound = errors.New("index not found")
+
+// DefaultName is the index used when none is named
+const DefaultName = "default"
+
+const (
+	// chunkSize is the target size of a chunk in bytes
+	chunkSize = 1500
+	// maxFileSize skips files too large to be documents, e.g. data dumps
+	maxFileSize = 1 << 20
+)
+
+// Extensions are the file types indexed when walking a directory. Files
+// named explicitly are indexed whatever their type.
+var Extensions = []string{".md", ".markdown", ".txt", ".rst", ".adoc", ".go", ".sh", ".yaml", ".yml"}
+
+// skipDirs are directories never walked into
+var skipDirs = map[string]bool{".git": true, "node_modules": true, "vendor": true}
+
+// Index is a set of document chunks and their embeddings. All vectors are
+// computed with the same provider and model.
+type Index struct {
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Files maps the absolute path of each indexed file to the hash of its
+	// content when it was indexed
+	Files  map[string]string `json:"files"`
+	Chunks []Chunk           `json:"chunks"`
+}
+
+// Chunk is a span of lines from a file
+type Chunk struct {
+	Source    string    `json:"source"`
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Text      string    `json:"text"`
+	Vector    []float32 `json:"vector"`
+}
+
+// Citation returns the chunk's file and lines, e.g. runbooks/db.md:12-40.
+// Paths under the working directory are shown relative to it.
+func (c Chunk) Citation() string {
+	return fmt.Sprintf("%s:%d-%d", displayPath(c.Source), c.StartLine, c.EndLine)
+}
+
+// Match is a chunk found by Search, with its cosine similarity to the query
+type Match struct {
+	Chunk
+	Score float32
+}
+
+// Changes describes how the files under some paths differ from the index
+type Changes struct {
+	// Changed files are new or modified, mapped to the hash of their content
+	Changed map[string]string
+	// Unchanged files are indexed with their current content
+	Unchanged int
+	// Removed files are indexed but no longer exist
+	Removed []string
+}
+
+// Empty reports whether there is nothing to update
+func (c *Changes) Empty() bool {
+	return len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+// New creates an empty, unsaved index
+func New(name string) *Index {
+	now := time.Now().UTC()
+	return &Index{Name: name, CreatedAt: now, UpdatedAt: now, Files: map[string]string{}}
+}
+
+// Dir returns the directory indexes are stored in
+func Dir() (string, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "index"), nil
+}
+
+// Load reads the named index
+func Load(name string) (*Index, error) {
+	path, err := indexPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to decode index %s: %w", name, err)
+	}
+	if idx.Files == nil {
+		idx.Files = map[string]string{}
+	}
+	return &idx, nil
+}
+
+// Save writes the index, stamping its update time
+func (idx *Index) Save() error {
+	path, err := indexPath(idx.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	idx.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	// Write atomically so an interrupted save never loses the index
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// Scan compares the files under paths with the index. A path may be a file
+// or a directory, which is walked for files with one of the Extensions.
+func (idx *Index) Scan(paths []string) (*Changes, error) {
+	changes := &Changes{Changed: map[string]string{}}
+	found := map[string]bool{}
+	roots := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		root, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+
+		files, err := collect(root)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if found[file] {
+				continue
+			}
+			found[file] = true
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			hash := hashContent(data)
+			if idx.Files[file] == hash {
+				changes.Unchanged++
+			} else {
+				changes.Changed[file] = hash
+			}
+		}
+	}
+
+	for file := range idx.Files {
+		if !found[file] && under(file, roots) {
+			changes.Removed = append(changes.Removed, file)
+		}
+	}
+	sort.Strings(changes.Removed)
+	return changes, nil
+}
+
+// Update applies changes, embedding the chunks of changed files with the
+// client's embedding model, and returns the number of chunks embedded. An
+// index can only hold vectors from one model, so updating it with another
+// is an error.
+func (idx *Index) Update(ctx context.Context, client *ai.Client, changes *Changes) (int, error) {
+	if err := idx.checkModel(client); err != nil {
+		return 0, err
+	}
+
+	files := make([]string, 0, len(changes.Changed))
+	for file := range changes.Changed {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var chunks []Chunk
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		chunks = append(chunks, split(file, string(data))...)
+	}
+
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	vectors, err := client.Embed(ctx, texts)
+	if err != nil {
+		return 0, err
+	}
+	for i := range chunks {
+		normalize(vectors[i])
+		chunks[i].Vector = vectors[i]
+	}
+
+	// Replace the chunks of changed and removed files
+	stale := map[string]bool{}
+	for _, file := range files {
+		stale[file] = true
+	}
+	for _, file := range changes.Removed {
+		stale[file] = true
+		delete(idx.Files, file)
+	}
+	kept := idx.Chunks[:0]
+	for _, c := range idx.Chunks {
+		if !stale[c.Source] {
+			kept = append(kept, c)
+		}
+	}
+	idx.Chunks = append(kept, chunks...)
+	for file, hash := range changes.Changed {
+		idx.Files[file] = hash
+	}
+
+	idx.Provider, idx.Model = client.Provider(), client.EmbeddingModel()
+	return len(chunks), nil
+}
+
+// Search returns the k chunks most similar to query, best first
+func (idx *Index) Search(ctx context.Context, client *ai.Client, query string, k int) ([]Match, error) {
+	if len(idx.Chunks) == 0 {
+		return nil, fmt.Errorf("index %q is empty", idx.Name)
+	}
+	if err := idx.checkModel(client); err != nil {
+		return nil, err
+	}
+
+	vectors, err := client.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := vectors[0]
+	normalize(q)
+
+	matches := make([]Match, 0, len(idx.Chunks))
+	for _, c := range idx.Chunks {
+		if len(c.Vector) != len(q) {
+			continue
+		}
+		matches = append(matches, Match{Chunk: c, Score: dot(q, c.Vector)})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// checkModel makes sure the client embeds with the model the index was
+// built with
+func (idx *Index) checkModel(client *ai.Client) error {
+	model := client.EmbeddingModel()
+	if model == "" {
+		return fmt.Errorf("%w: %s (select another with --provider)", ai.ErrEmbeddingsUnsupported, client.Provider())
+	}
+	if idx.Model != "" && (idx.Provider != client.Provider() || idx.Model != model) {
+		return fmt.Errorf("index %q was built with %s model %s, not %s model %s; rebuild it with `${{values.name}} ai index --rebuild`",
+			idx.Name, idx.Provider, idx.Model, client.Provider(), model)
+	}
+	return nil
+}
+
+// split divides a file into chunks of whole lines of about chunkSize bytes.
+// Markdown files are also split at headings, so that chunks tend to cover
+// one section. Lines longer than a chunk, e.g. in minified files, are
+// truncated.
+func split(source, text string) []Chunk {
+	markdown := strings.HasSuffix(source, ".md") || strings.HasSuffix(source, ".markdown")
+
+	var chunks []Chunk
+	var b strings.Builder
+	start := 1
+	flush := func(end int) {
+		if body := strings.TrimSpace(b.String()); body != "" {
+			chunks = append(chunks, Chunk{Source: source, StartLine: start, EndLine: end, Text: body})
+		}
+		b.Reset()
+		start = end + 1
+	}
+
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if len(line) > chunkSize {
+			line = line[:chunkSize] + "\n"
+		}
+		heading := markdown && strings.HasPrefix(line, "#") && b.Len() > chunkSize/4
+		if b.Len() > 0 && (b.Len()+len(line) > chunkSize || heading) {
+			flush(i)
+		}
+		b.WriteString(line)
+	}
+	flush(len(lines))
+	return chunks
+}
+
+// collect returns the absolute paths of the files to index under root
+func collect(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !hasExtension(path) {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+	return files, nil
+}
+
+func hasExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// under reports whether path is one of roots or inside one of them
+func under(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// displayPath shortens paths under the working directory
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// normalize scales v to unit length in place, so that the dot product of
+// two normalized vectors is their cosine similarity
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func indexPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid index name %q", name)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+{%- else %}
+package index
+{%- endif %}
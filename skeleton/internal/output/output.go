@@ -2,10 +2,19 @@
 package output
 
 import (
+	"bufio"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+{%- if values.outputFormat != "charm" %}
+	"strconv"
+	"strings"
+{%- endif %}
 {%- if values.outputFormat == "charm" %}
+	"errors"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
@@ -14,6 +23,7 @@ import (
 {%- elif values.outputFormat == "tablewriter" %}
 	"github.com/olekukonko/tablewriter"
 {%- endif %}
+	"golang.org/x/term"
 {%- if values.configFormat == "yaml" or values.configFormat == "all" %}
 	"gopkg.in/yaml.v3"
 {%- endif %}
@@ -35,6 +45,17 @@ var (
 type Formatter struct {
 	format string
 	color  bool
+	in     *bufio.Reader
+}
+
+// Report is data from a test-like command, such as ai eval. It is output as
+// its rows in tables, as itself with the json and yaml formats, for
+// scripts, or as JUnit XML with the junit format.
+type Report interface {
+	Rows() []map[string]any
+	// JUnit returns the report as a value that encoding/xml marshals to
+	// JUnit XML
+	JUnit() any
 }
 
 // NewFormatter creates a new output formatter
@@ -42,12 +63,25 @@ func NewFormatter(format string) *Formatter {
 	return &Formatter{
 		format: format,
 		color:  true, // TODO: read from global flags
+		in:     bufio.NewReader(os.Stdin),
 	}
 }
 
 // Data outputs data in the configured format
 func (f *Formatter) Data(data any, title string) error {
+	if r, ok := data.(Report); ok {
+		if f.format == "junit" {
+			return f.JUnit(r)
+		}
+		// JSON and YAML keep the whole report, outputs included
+		if f.format != "json" && f.format != "yaml" {
+			data = r.Rows()
+		}
+	}
+
 	switch f.format {
+	case "junit":
+		return fmt.Errorf("junit format is only supported for reports, such as ai eval")
 	case "json":
 		return f.JSON(data)
 	case "yaml":
@@ -66,6 +100,18 @@ func (f *Formatter) JSON(data any) error {
 	return enc.Encode(data)
 }
 
+// JUnit outputs a report as JUnit XML
+func (f *Formatter) JUnit(r Report) error {
+	fmt.Print(xml.Header)
+	enc := xml.NewEncoder(os.Stdout)
+	enc.Indent("", "  ")
+	if err := enc.Encode(r.JUnit()); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
+
 // YAML outputs data as YAML
 func (f *Formatter) YAML(data any) error {
 {%- if values.configFormat == "yaml" or values.configFormat == "all" %}
@@ -80,7 +126,7 @@ func (f *Formatter) YAML(data any) error {
 // Table outputs data as a table
 func (f *Formatter) Table(data any, title string) error {
 	// Convert to []map[string]any for table rendering
-	items, ok := data.([]map[string]any)
+	items, ok := tableRows(data)
 	if !ok {
 		return fmt.Errorf("table format requires []map[string]any")
 	}
@@ -91,11 +137,12 @@ func (f *Formatter) Table(data any, title string) error {
 	}
 
 {%- if values.outputFormat == "charm" %}
-	// Extract headers from first item
+	// Extract headers from first item, sorted for a stable column order
 	var headers []string
 	for k := range items[0] {
 		headers = append(headers, k)
 	}
+	sort.Strings(headers)
 
 	// Build rows
 	var rows []table.Row
@@ -125,11 +172,12 @@ func (f *Formatter) Table(data any, title string) error {
 	}
 	fmt.Println(t.View())
 {%- elif values.outputFormat == "tablewriter" %}
-	// Extract headers
+	// Extract headers, sorted for a stable column order
 	var headers []string
 	for k := range items[0] {
 		headers = append(headers, k)
 	}
+	sort.Strings(headers)
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader(headers)
@@ -152,8 +200,13 @@ func (f *Formatter) Table(data any, title string) error {
 		fmt.Println(title)
 	}
 	for _, item := range items {
-		for k, v := range item {
-			fmt.Printf("%s: %v\n", k, v)
+		keys := make([]string, 0, len(item))
+		for k := range item {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s: %v\n", k, item[k])
 		}
 		fmt.Println()
 	}
@@ -162,6 +215,29 @@ func (f *Formatter) Table(data any, title string) error {
 	return nil
 }
 
+// tableRows converts data to table rows. Besides []map[string]any it accepts
+// decoded JSON: a single object, or an array of objects.
+func tableRows(data any) ([]map[string]any, bool) {
+	switch v := data.(type) {
+	case []map[string]any:
+		return v, true
+	case map[string]any:
+		return []map[string]any{v}, true
+	case []any:
+		rows := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			row, ok := item.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			rows = append(rows, row)
+		}
+		return rows, true
+	default:
+		return nil, false
+	}
+}
+
 // Auto automatically selects format based on data type
 func (f *Formatter) Auto(data any, title string) error {
 	switch v := data.(type) {
@@ -208,6 +284,17 @@ func (f *Formatter) Info(msg string) {
 {%- endif %}
 }
 
+// Stream prints an incremental chunk of text without a trailing newline.
+// Call StreamEnd once the stream is complete.
+func (f *Formatter) Stream(text string) {
+	fmt.Print(text)
+}
+
+// StreamEnd terminates a sequence of Stream calls
+func (f *Formatter) StreamEnd() {
+	fmt.Println()
+}
+
 // Confirm prompts the user for confirmation
 func (f *Formatter) Confirm(message string, defaultValue bool) bool {
 {%- if values.outputFormat == "charm" %}
@@ -244,6 +331,122 @@ func (f *Formatter) Confirm(message string, defaultValue bool) bool {
 {%- endif %}
 }
 
+// Prompt reads a line of input from the user. It returns io.EOF when the
+// input is closed or the user aborts.
+func (f *Formatter) Prompt(label string) (string, error) {
+{%- if values.outputFormat == "charm" %}
+	var value string
+	err := huh.NewInput().
+		Title(label).
+		Value(&value).
+		Run()
+	if errors.Is(err, huh.ErrUserAborted) {
+		return "", io.EOF
+	}
+	if err != nil {
+		return "", err
+	}
+	fmt.Println(StyleTitle.Render(label+" ›") + " " + value)
+	return value, nil
+{%- else %}
+	fmt.Printf("%s> ", label)
+	line, err := f.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return line, nil
+{%- endif %}
+}
+
+// PromptSecret reads a secret from the user without echoing it. Piped
+// input is read to its end instead, without the final newline. It returns
+// io.EOF when the input is closed or the user aborts.
+func (f *Formatter) PromptSecret(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		data, err := io.ReadAll(f.in)
+		if err != nil {
+			return "", err
+		}
+		if len(data) == 0 {
+			return "", io.EOF
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+{%- if values.outputFormat == "charm" %}
+	var value string
+	err := huh.NewInput().
+		Title(label).
+		EchoMode(huh.EchoModePassword).
+		Value(&value).
+		Run()
+	if errors.Is(err, huh.ErrUserAborted) {
+		return "", io.EOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+{%- else %}
+	fmt.Printf("%s> ", label)
+	value, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+{%- endif %}
+}
+
+// Select asks the user to choose one of options, and returns defaultValue
+// if they just press enter. It returns io.EOF when the input is closed or
+// the user aborts.
+func (f *Formatter) Select(label string, options []string, defaultValue string) (string, error) {
+{%- if values.outputFormat == "charm" %}
+	value := defaultValue
+	err := huh.NewSelect[string]().
+		Title(label).
+		Options(huh.NewOptions(options...)...).
+		Value(&value).
+		Run()
+	if errors.Is(err, huh.ErrUserAborted) {
+		return "", io.EOF
+	}
+	if err != nil {
+		return "", err
+	}
+	fmt.Println(StyleTitle.Render(label+" ›") + " " + value)
+	return value, nil
+{%- else %}
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option)
+	}
+	for {
+		line, err := f.Prompt(fmt.Sprintf("%s [%s]", label, defaultValue))
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return defaultValue, nil
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(options) {
+			return options[n-1], nil
+		}
+		for _, option := range options {
+			if option == line {
+				return option, nil
+			}
+		}
+		f.Warning(fmt.Sprintf("Enter a number from 1 to %d, or one of the names", len(options)))
+	}
+{%- endif %}
+}
+
 // DryRun prints what would happen in dry-run mode
 func (f *Formatter) DryRun(format string, args ...any) {
 	msg := fmt.Sprintf(format, args...)
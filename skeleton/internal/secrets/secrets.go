@@ -0,0 +1,319 @@
+// Package secrets keeps named secrets in a local file encrypted with age
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"filippo.io/age"
+	"golang.org/x/term"
+)
+
+// ErrNotFound is returned when no secret has a name
+var ErrNotFound = errors.New("secret not found")
+
+// ageHeader starts files encrypted with age, such as a protected identity
+const ageHeader = "age-encryption.org/v1\n"
+
+// validName matches the names secrets may have
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Store is a set of named secrets in a file encrypted with age. The file is
+// encrypted to an X25519 identity kept next to it, which is created with
+// the first secret: the file is safe to back up or sync, the identity is
+// not. Anyone who can read the identity can decrypt the file, unless the
+// identity is itself encrypted with a passphrase.
+type Store struct {
+	path    string
+	keyPath string
+
+	// Passphrase returns the passphrase of a protected identity. It is
+	// called at most once, when the identity is first needed.
+	Passphrase func() (string, error)
+
+	key *age.X25519Identity // once read
+}
+
+// NewStore creates a store for the secrets file and identity in dir
+func NewStore(dir string) *Store {
+	return &Store{
+		path:    filepath.Join(dir, "secrets.age"),
+		keyPath: filepath.Join(dir, "secrets.key"),
+	}
+}
+
+// Path returns the encrypted secrets file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// KeyPath returns the identity that decrypts the file
+func (s *Store) KeyPath() string {
+	return s.keyPath
+}
+
+// Get returns the value of a secret
+func (s *Store) Get(name string) (string, error) {
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Set stores a secret, replacing any with the same name
+func (s *Store) Set(name, value string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.save(secrets)
+}
+
+// CheckName returns an error if name is not a valid secret name
+func CheckName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '.', '-' and '_'", name)
+	}
+	return nil
+}
+
+// Remove deletes a secret, reporting whether it existed
+func (s *Store) Remove(name string) (bool, error) {
+	secrets, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := secrets[name]; !ok {
+		return false, nil
+	}
+	delete(secrets, name)
+	return true, s.save(secrets)
+}
+
+// Names returns the names of the stored secrets, sorted
+func (s *Store) Names() ([]string, error) {
+	secrets, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// load decrypts the secrets; a missing file holds none
+func (s *Store) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	identity, err := s.identity(false)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with %s: %w", s.path, s.keyPath, err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", s.path, err)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", s.path, err)
+	}
+	return secrets, nil
+}
+
+// save encrypts the secrets and writes them atomically
+func (s *Store) save(secrets map[string]string) error {
+	identity, err := s.identity(true)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, identity.Recipient())
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	return writeFile(s.path, buf.Bytes())
+}
+
+// Protected reports whether the identity is encrypted with a passphrase
+func (s *Store) Protected() (bool, error) {
+	data, err := os.ReadFile(s.keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read secrets key: %w", err)
+	}
+	return bytes.HasPrefix(data, []byte(ageHeader)), nil
+}
+
+// Unlock reads the identity, asking for its passphrase if it is protected,
+// and creates it if there is none
+func (s *Store) Unlock() error {
+	_, err := s.identity(true)
+	return err
+}
+
+// SetPassphrase encrypts the identity with passphrase, which the store then
+// asks for when it first needs the identity. An empty passphrase removes
+// the protection.
+func (s *Store) SetPassphrase(passphrase string) error {
+	identity, err := s.identity(true)
+	if err != nil {
+		return err
+	}
+	key := []byte(fmt.Sprintf("# Decrypts %s; keep it private\n%s\n", s.path, identity))
+	if passphrase == "" {
+		return writeFile(s.keyPath, key)
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return fmt.Errorf("invalid passphrase: %w", err)
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets key: %w", err)
+	}
+	if _, err := w.Write(key); err != nil {
+		return fmt.Errorf("failed to encrypt secrets key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt secrets key: %w", err)
+	}
+	return writeFile(s.keyPath, buf.Bytes())
+}
+
+// identity reads the age identity, generating it first if create is set
+func (s *Store) identity(create bool) (*age.X25519Identity, error) {
+	if s.key != nil {
+		return s.key, nil
+	}
+	data, err := os.ReadFile(s.keyPath)
+	if errors.Is(err, os.ErrNotExist) && create {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate secrets key: %w", err)
+		}
+		key := fmt.Sprintf("# Decrypts %s; keep it private\n%s\n", s.path, identity)
+		if err := writeFile(s.keyPath, []byte(key)); err != nil {
+			return nil, err
+		}
+		s.key = identity
+		return identity, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets key: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte(ageHeader)) {
+		if data, err = s.decryptKey(data); err != nil {
+			return nil, err
+		}
+	}
+
+	identities, err := age.ParseIdentities(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid secrets key %s: %w", s.keyPath, err)
+	}
+	identity, ok := identities[0].(*age.X25519Identity)
+	if !ok {
+		return nil, fmt.Errorf("invalid secrets key %s: expected an X25519 identity", s.keyPath)
+	}
+	s.key = identity
+	return identity, nil
+}
+
+// decryptKey decrypts an identity protected with a passphrase
+func (s *Store) decryptKey(data []byte) ([]byte, error) {
+	if s.Passphrase == nil {
+		return nil, fmt.Errorf("secrets key %s is protected by a passphrase", s.keyPath)
+	}
+	passphrase, err := s.Passphrase()
+	if err != nil {
+		return nil, err
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid passphrase: %w", err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets key %s: wrong passphrase?", s.keyPath)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets key %s: %w", s.keyPath, err)
+	}
+	return plain, nil
+}
+
+// ReadPassphrase asks for a passphrase on the terminal, without echoing it
+func ReadPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no terminal to ask for the passphrase on")
+	}
+	fmt.Fprint(os.Stderr, prompt+": ")
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
+
+// writeFile writes data to path atomically, readable only by the user
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+{%- if values.aiProvider != "none" %}
+// Package session persists AI conversations on disk
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fast-ish/${{values.name}}/internal/ai"
+	"github.com/fast-ish/${{values.name}}/internal/config"
+)
+
+// ErrNotFound is returned when no session matches an ID
+var ErrNotFound = errors.New("session not found")
+
+// Session is a saved conversation transcript
+type Session struct {
+	ID        string    `json:"id" yaml:"id"`
+	Title     string    `json:"title" yaml:"title"`
+	Provider  string    `json:"provider" yaml:"provider"`
+	Model     string    `json:"model" yaml:"model"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+	Usage     ai.Usage  `json:"usage" yaml:"usage"`
+
+	ai.Conversation `yaml:",inline"`
+}
+
+// New creates an unsaved session, optionally seeded with a system prompt
+func New(provider, model, system string) *Session {
+	now := time.Now().UTC()
+	return &Session{
+		ID:           newID(now),
+		Provider:     provider,
+		Model:        model,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Conversation: *ai.NewConversation(system),
+	}
+}
+
+// Turns returns the number of user messages in the session
+func (s *Session) Turns() int {
+	n := 0
+	for _, m := range s.Messages {
+		if m.Role == ai.RoleUser {
+			n++
+		}
+	}
+	return n
+}
+
+// Markdown renders the transcript as a Markdown document
+func (s *Session) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", s.Title)
+	fmt.Fprintf(&b, "- Session: `%s`\n", s.ID)
+	fmt.Fprintf(&b, "- Model: %s (%s)\n", s.Model, s.Provider)
+	fmt.Fprintf(&b, "- Started: %s\n", s.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Tokens: %d in, %d out\n", s.Usage.InputTokens, s.Usage.OutputTokens)
+
+	for _, m := range s.Messages {
+		role := string(m.Role)
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", strings.ToUpper(role[:1])+role[1:], m.Content)
+	}
+	return b.String()
+}
+
+// Store reads and writes sessions as JSON files in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// DefaultStore returns the store under the user's config directory
+func DefaultStore() (*Store, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(filepath.Join(dir, "sessions")), nil
+}
+
+// Save writes the session, stamping its update time and deriving a title
+// from the first user message if it has none
+func (st *Store) Save(s *Session) error {
+	if err := os.MkdirAll(st.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	s.UpdatedAt = time.Now().UTC()
+	if s.Title == "" {
+		s.Title = deriveTitle(s.Messages)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// Write atomically so an interrupted save never truncates a transcript
+	tmp := st.path(s.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, st.path(s.ID)); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// Load reads a session by ID or unique ID prefix
+func (st *Store) Load(id string) (*Session, error) {
+	id, err := st.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return st.read(st.path(id))
+}
+
+// List returns all sessions, most recently updated first
+func (st *Store) List() ([]*Session, error) {
+	paths, err := filepath.Glob(filepath.Join(st.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]*Session, 0, len(paths))
+	for _, p := range paths {
+		s, err := st.read(p)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+// Delete removes a session by ID or unique ID prefix
+func (st *Store) Delete(id string) error {
+	id, err := st.resolve(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(st.path(id)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// resolve expands an ID prefix to a full session ID
+func (st *Store) resolve(prefix string) (string, error) {
+	if prefix == "" || strings.ContainsAny(prefix, `/\*?[`) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, prefix)
+	}
+	if _, err := os.Stat(st.path(prefix)); err == nil {
+		return prefix, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(st.dir, prefix+"*.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to look up session: %w", err)
+	}
+	switch len(paths) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrNotFound, prefix)
+	case 1:
+		return strings.TrimSuffix(filepath.Base(paths[0]), ".json"), nil
+	default:
+		return "", fmt.Errorf("session ID %q is ambiguous (%d matches)", prefix, len(paths))
+	}
+}
+
+func (st *Store) read(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", filepath.Base(path), err)
+	}
+	return &s, nil
+}
+
+func (st *Store) path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+// newID returns a sortable, collision-resistant session ID
+func newID(t time.Time) string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// deriveTitle uses the first line of the first user message as a title
+func deriveTitle(messages []ai.Message) string {
+	for _, m := range messages {
+		if m.Role != ai.RoleUser {
+			continue
+		}
+		title := strings.TrimSpace(strings.SplitN(m.Content, "\n", 2)[0])
+		if runes := []rune(title); len(runes) > 60 {
+			title = string(runes[:57]) + "..."
+		}
+		return title
+	}
+	return "Untitled session"
+}
+{%- else %}
+// Package session is a placeholder when AI is not enabled
+package session
+{%- endif %}